@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bunnyfms/internal/driverstation"
+	"github.com/natesales/bunnyfms/internal/dssim"
+)
+
+var (
+	fmsIP          = flag.String("fms", "127.0.0.1", "FMS address")
+	teams          = flag.String("teams", "1,2,3,4,5,6", "Comma separated team numbers, one simulated driver station each")
+	localIP        = flag.String("local-ip", "127.0.0.10", "Address of the first simulated driver station, incremented for each one after it")
+	batteryVoltage = flag.Float64("battery", 12.5, "Robot battery voltage")
+	robotLinked    = flag.Bool("robot-linked", true, "Start with the robot linked")
+	flapInterval   = flag.Duration("flap", 0, "Toggle the robot link this often (0 to disable)")
+	statusInterval = flag.Duration("status-interval", 250*time.Millisecond, "Time between UDP status packets")
+	reportInterval = flag.Duration("report", time.Second, "Time between control packet reports")
+	tcpPort        = flag.Int("tcp-port", driverstation.DefaultTcpListenPort, "FMS TCP port")
+	udpPort        = flag.Int("udp-port", driverstation.DefaultUdpReceivePort, "FMS UDP status port")
+	controlPort    = flag.Int("control-port", driverstation.DefaultUdpSendPort, "Local UDP port to receive control packets on")
+	verbose        = flag.Bool("v", false, "Enable debug logging")
+)
+
+// nextIP gets the address n after ip
+func nextIP(ip net.IP, n int) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0 && n > 0; i-- {
+		sum := int(next[i]) + n
+		next[i] = byte(sum)
+		n = sum >> 8
+	}
+	return next
+}
+
+func main() {
+	flag.Parse()
+	if *verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	baseIP := net.ParseIP(*localIP).To4()
+	if baseIP == nil {
+		log.Fatalf("Invalid local IP %s", *localIP)
+	}
+
+	var driverStations []*dssim.DriverStation
+	for i, team := range strings.Split(*teams, ",") {
+		teamNumber, err := strconv.Atoi(strings.TrimSpace(team))
+		if err != nil {
+			log.Fatalf("Invalid team number %q", team)
+		}
+
+		ds := dssim.New(dssim.Config{
+			Team:           teamNumber,
+			LocalIP:        nextIP(baseIP, i).String(),
+			FmsIP:          *fmsIP,
+			TcpPort:        *tcpPort,
+			UdpPort:        *udpPort,
+			ControlPort:    *controlPort,
+			StatusInterval: *statusInterval,
+			BatteryVoltage: *batteryVoltage,
+			RobotLinked:    *robotLinked,
+			FlapInterval:   *flapInterval,
+		})
+		if err := ds.Start(); err != nil {
+			log.Fatalf("Unable to start driver station for team %d: %v", teamNumber, err)
+		}
+		defer ds.Close()
+		log.Infof("Simulating team %d on %s", teamNumber, nextIP(baseIP, i))
+		driverStations = append(driverStations, ds)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(*reportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-interrupt:
+			log.Info("Stopping driver stations")
+			return
+		case <-ticker.C:
+			for i, ds := range driverStations {
+				packet, count := ds.LastControlPacket()
+				_, wrongStation, connected := ds.Assignment()
+				log.Infof("DS %d: connected=%v wrong_station=%v packets=%d last=%s game_data=%q",
+					i+1, connected, wrongStation, count, packet, ds.GameData())
+			}
+		}
+	}
+}
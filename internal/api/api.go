@@ -5,7 +5,6 @@ import (
 	"github.com/gofiber/websocket/v2"
 	log "github.com/sirupsen/logrus"
 
-	"github.com/natesales/bunnyfms/internal/driverstation"
 	"github.com/natesales/bunnyfms/internal/field"
 )
 
@@ -14,87 +13,52 @@ var (
 	appViewer *fiber.App
 )
 
-type message struct {
-	Message         string         `json:"message"`
-	AllianceStation string         `json:"alliance_station"`
-	Alliances       map[string]int `json:"alliances"`
-	Name            string         `json:"name"`
-}
-
-func setupAdmin() {
+func setupAdmin(arena *field.Arena, h *hub) {
 	appAdmin = fiber.New(fiber.Config{DisableStartupMessage: true})
 	appAdmin.Static("/", "static/")
+	setupREST(appAdmin.Group("/api"), arena, h)
 
-	appAdmin.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		for {
-			var msg message
-			if err := c.ReadJSON(&msg); err != nil {
-				log.Println("read:", err)
-				break
+	appAdmin.Get("/ws", websocket.New(func(conn *websocket.Conn) {
+		h.serve(conn, func(c *client, data []byte) {
+			cmd, err := runCommand(arena, data)
+			var id string
+			if cmd != nil {
+				id = cmd.ID
+				log.Debugf("Admin command %s (%s): %s", cmd.Command, cmd.ID, cmd.Data)
 			}
-
-			switch msg.Message {
-			case "ping":
-				if err := c.WriteJSON(field.State()); err != nil {
-					log.Println("write:", err)
-				}
-			case "start":
-				log.Debug("Starting match")
-				field.Start()
-			case "stop":
-				log.Debug("Stopping match")
-				field.Stop()
-			case "ds_reconnect":
-				log.Debug("Reconnecting to driver stations")
-				driverstation.ResetComms()
-			case "estop":
-				log.Debugf("Estopping %s", msg.AllianceStation)
-				driverstation.Estop(msg.AllianceStation)
-			case "test_sounds":
-				log.Debug("Playing all sounds")
-				field.PlayAllSounds()
-			case "update_alliances":
-				log.Debugf("Updating alliances to %+v", msg.Alliances)
-				field.UpdateTeamNumbers(msg.Alliances)
-			case "match_name":
-				log.Debugf("Updating match name to %+v", msg.Name)
-				field.UpdateMatchName(msg.Name)
-			case "reset_alliances":
-				log.Debug("Resetting alliances")
-				field.ResetAlliances()
+			if err != nil {
+				log.Warnf("Admin command %s failed: %v", id, err)
+			}
+			c.queueJSON(newResult(id, err))
+			if err == nil {
+				h.notify()
 			}
-		}
+		})
 	}))
 }
 
-func setupViewer() {
+func setupViewer(h *hub) {
 	appViewer = fiber.New(fiber.Config{DisableStartupMessage: true})
 
 	appViewer.Get("/", func(c *fiber.Ctx) error {
 		return c.SendFile("static/viewer.html")
 	})
 
-	appViewer.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		for {
-			var msg message
-			if err := c.ReadJSON(&msg); err != nil {
-				log.Println("read:", err)
-				break
-			}
-			if err := c.WriteJSON(field.State()); err != nil {
-				log.Println("write:", err)
-			}
-		}
+	appViewer.Get("/ws", websocket.New(func(conn *websocket.Conn) {
+		h.serve(conn, nil)
 	}))
 }
 
 // Serve starts the API server
-func Serve(adminListen, viewerListen string) {
+func Serve(arena *field.Arena, adminListen, viewerListen string) {
+	h := newHub(arena)
+	go h.run()
+
 	if appAdmin == nil {
-		setupAdmin()
+		setupAdmin(arena, h)
 	}
 	if appViewer == nil {
-		setupViewer()
+		setupViewer(h)
 	}
 
 	go func() {
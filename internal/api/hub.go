@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bunnyfms/internal/field"
+)
+
+const (
+	matchBroadcastPeriod = 100 * time.Millisecond // Time between state broadcasts during a match
+	idleBroadcastPeriod  = time.Second            // Time between state broadcasts between matches
+	clientBuffer         = 16                     // Messages queued for a client before it's dropped as too slow
+	writeTimeout         = 2 * time.Second        // Time allowed to write a message to a client
+)
+
+// client is a websocket subscriber to the hub
+type client struct {
+	name string
+	send chan []byte
+	done chan struct{} // Closed when the client is dropped
+	once sync.Once
+}
+
+func newClient(name string) *client {
+	return &client{name: name, send: make(chan []byte, clientBuffer), done: make(chan struct{})}
+}
+
+// queue adds a message to the client's queue, dropping the client if the queue is full
+func (c *client) queue(msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		log.Warnf("Dropping websocket client %s: too slow to keep up with state updates", c.name)
+		c.close()
+	}
+}
+
+// queueJSON encodes v and adds it to the client's queue
+func (c *client) queueJSON(v interface{}) {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		log.Warnf("Unable to encode message for %s: %v", c.name, err)
+		return
+	}
+	c.queue(msg)
+}
+
+func (c *client) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// writeLoop writes queued messages to conn until the client is dropped or a write fails. It is the only writer on
+// the connection.
+func (c *client) writeLoop(conn *websocket.Conn) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				log.Println("write:", err)
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// hub pushes the same field state to every subscriber when the match changes state and on a fixed tick, so all
+// displays update together
+type hub struct {
+	arena   *field.Arena
+	changed chan struct{}
+
+	mu      sync.Mutex
+	clients map[*client]bool
+}
+
+func newHub(arena *field.Arena) *hub {
+	return &hub{arena: arena, changed: make(chan struct{}, 1), clients: map[*client]bool{}}
+}
+
+func (h *hub) subscribe(c *client) {
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+	h.notify()
+}
+
+func (h *hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// notify asks for a broadcast as soon as possible, such as after an admin command
+func (h *hub) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// broadcast sends one snapshot of the field state to every subscriber
+func (h *hub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.clients) == 0 {
+		return
+	}
+
+	msg, err := json.Marshal(newStateMessage(h.arena.State()))
+	if err != nil {
+		log.Warnf("Unable to encode field state: %v", err)
+		return
+	}
+	for c := range h.clients {
+		c.queue(msg)
+	}
+}
+
+// run broadcasts the state on every change, every matchBroadcastPeriod during a match and every idleBroadcastPeriod
+// otherwise
+func (h *hub) run() {
+	ticker := time.NewTicker(matchBroadcastPeriod)
+	defer ticker.Stop()
+
+	var last time.Time
+	for {
+		select {
+		case <-h.changed:
+		case <-h.arena.Changes():
+		case <-ticker.C:
+			if !h.arena.Running() && time.Since(last) < idleBroadcastPeriod {
+				continue
+			}
+		}
+		h.broadcast()
+		last = time.Now()
+	}
+}
+
+// serve subscribes a websocket connection and writes to it until it closes. Each message read from the connection is
+// passed to onMessage, which may queue replies on the client.
+func (h *hub) serve(conn *websocket.Conn, onMessage func(c *client, data []byte)) {
+	c := newClient(conn.RemoteAddr().String())
+	h.subscribe(c)
+	defer h.unsubscribe(c)
+
+	go func() {
+		defer c.close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				log.Println("read:", err)
+				return
+			}
+			if onMessage != nil {
+				onMessage(c, data)
+			}
+		}
+	}()
+
+	c.writeLoop(conn)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/natesales/bunnyfms/internal/field"
+)
+
+func TestHubBroadcast(t *testing.T) {
+	arena, err := field.NewArena(field.Config{
+		AutoDuration:   15 * time.Second,
+		TeleopDuration: 2*time.Minute + 15*time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHub(arena)
+
+	admin, viewer, slow := newClient("admin"), newClient("viewer"), newClient("slow")
+	h.subscribe(admin)
+	h.subscribe(viewer)
+	h.subscribe(slow)
+	for i := 0; i < clientBuffer; i++ {
+		slow.queue([]byte("{}"))
+	}
+
+	h.broadcast()
+
+	// Every subscriber gets the same snapshot
+	a, v := <-admin.send, <-viewer.send
+	if !bytes.Equal(a, v) {
+		t.Errorf("subscribers got different states:\n%s\n%s", a, v)
+	}
+	var msg stateMessage
+	if err := json.Unmarshal(a, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Version != protocolVersion || msg.Type != typeState || msg.State["state"] != "Idle" {
+		t.Errorf("unexpected state message %s", a)
+	}
+
+	// A client with a full queue is dropped rather than holding up the others
+	select {
+	case <-slow.done:
+	default:
+		t.Error("slow client wasn't dropped")
+	}
+	select {
+	case <-admin.done:
+		t.Error("admin client was dropped")
+	default:
+	}
+
+	// Unsubscribed clients get nothing more
+	h.unsubscribe(viewer)
+	h.broadcast()
+	if len(viewer.send) != 0 {
+		t.Error("unsubscribed client got a broadcast")
+	}
+	if len(admin.send) != 1 {
+		t.Errorf("admin client has %d queued messages, want 1", len(admin.send))
+	}
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/natesales/bunnyfms/internal/driverstation"
+	"github.com/natesales/bunnyfms/internal/field"
+)
+
+// protocolVersion is the version of the admin websocket protocol. Commands from other versions are rejected.
+const protocolVersion = 1
+
+// command is a request from an admin client. Data holds the arguments for the command, which depend on its name.
+type command struct {
+	Version int             `json:"v"`
+	ID      string          `json:"id"`
+	Command string          `json:"command"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Types of message sent to clients
+const (
+	typeResult = "result"
+	typeState  = "state"
+)
+
+// result is the reply to a command, with the reason it failed if it isn't ok
+type result struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newResult(id string, err error) result {
+	r := result{Version: protocolVersion, Type: typeResult, ID: id, OK: err == nil}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// stateMessage carries the field state to a client
+type stateMessage struct {
+	Version int                    `json:"v"`
+	Type    string                 `json:"type"`
+	State   map[string]interface{} `json:"state"`
+}
+
+func newStateMessage(state map[string]interface{}) stateMessage {
+	return stateMessage{Version: protocolVersion, Type: typeState, State: state}
+}
+
+type startArgs struct {
+	Force bool `json:"force"` // Start even if not every station is ready
+}
+
+type stationArgs struct {
+	AllianceStation string `json:"alliance_station"`
+}
+
+type bypassArgs struct {
+	AllianceStation string `json:"alliance_station"`
+	Bypass          bool   `json:"bypass"`
+}
+
+type allianceBypassArgs struct {
+	Alliance string `json:"alliance"`
+	Bypass   bool   `json:"bypass"`
+}
+
+type robotModeArgs struct {
+	AllianceStation string                  `json:"alliance_station"`
+	Mode            driverstation.RobotMode `json:"mode"`
+	Enabled         bool                    `json:"enabled"`
+}
+
+type alliancesArgs struct {
+	Alliances map[string]int `json:"alliances"`
+}
+
+type matchArgs struct {
+	Match field.Match `json:"match"`
+}
+
+type gameDataArgs struct {
+	Alliance string `json:"alliance"`
+	GameData string `json:"game_data"`
+}
+
+type speedArgs struct {
+	Speed float64 `json:"speed"`
+}
+
+type timeoutArgs struct {
+	Duration string `json:"duration"` // Go duration, such as "6m"
+}
+
+type noArgs struct{}
+
+// commandHandler runs a command with its undecoded arguments. source names the transport the command came from, such
+// as "admin" or "rest", for the e-stop audit trail.
+type commandHandler func(arena *field.Arena, source string, data json.RawMessage) error
+
+// decodeArgs decodes a command's arguments, rejecting unknown fields
+func decodeArgs(data json.RawMessage, args interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(args); err != nil {
+		return &field.ArgumentError{Err: fmt.Errorf("invalid arguments: %v", err)}
+	}
+	return nil
+}
+
+// adminCommands are the commands accepted on the admin websocket
+var adminCommands = map[string]commandHandler{
+	"ping": func(arena *field.Arena, source string, data json.RawMessage) error {
+		return decodeArgs(data, &noArgs{})
+	},
+	"start": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args startArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.Start(args.Force)
+	},
+	"stop": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.Stop()
+	},
+	"pause": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.Pause()
+	},
+	"resume": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.Resume()
+	},
+	"timeout": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args timeoutArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(args.Duration)
+		if err != nil {
+			return &field.ArgumentError{Err: err}
+		}
+		return arena.StartTimeout(d)
+	},
+	"cancel_timeout": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.CancelTimeout()
+	},
+	"field_test_start": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.StartFieldTest()
+	},
+	"field_test_stop": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.StopFieldTest()
+	},
+	"robot_mode": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args robotModeArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.SetRobotMode(args.AllianceStation, args.Mode, args.Enabled)
+	},
+	"ds_reconnect": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.ResetComms()
+	},
+	"estop": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args stationArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.Estop(args.AllianceStation, source)
+	},
+	"clear_estop": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args stationArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.ClearEstop(args.AllianceStation, source)
+	},
+	"astop": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args stationArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.Astop(args.AllianceStation)
+	},
+	"bypass": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args bypassArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.SetBypass(args.AllianceStation, args.Bypass)
+	},
+	"bypass_alliance": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args allianceBypassArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.SetAllianceBypass(args.Alliance, args.Bypass)
+	},
+	"test_sounds": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		arena.PlayAllSounds()
+		return nil
+	},
+	"update_alliances": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args alliancesArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.UpdateTeamNumbers(args.Alliances)
+	},
+	"match": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args matchArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.UpdateMatch(args.Match)
+	},
+	"game_data": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args gameDataArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.SetGameData(args.Alliance, args.GameData)
+	},
+	"speed": func(arena *field.Arena, source string, data json.RawMessage) error {
+		var args speedArgs
+		if err := decodeArgs(data, &args); err != nil {
+			return err
+		}
+		return arena.SetSpeed(args.Speed)
+	},
+	"reset_alliances": func(arena *field.Arena, source string, data json.RawMessage) error {
+		if err := decodeArgs(data, &noArgs{}); err != nil {
+			return err
+		}
+		return arena.ResetAlliances()
+	},
+}
+
+// sourceAdmin is the source of commands from the admin websocket
+const sourceAdmin = "admin"
+
+// runCommand decodes and runs a command from an admin client, returning its ID and result
+func runCommand(arena *field.Arena, data []byte) (*command, error) {
+	var cmd command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return nil, fmt.Errorf("invalid command: %v", err)
+	}
+	if cmd.Version != protocolVersion {
+		return &cmd, fmt.Errorf("unsupported protocol version %d, expected %d", cmd.Version, protocolVersion)
+	}
+	if cmd.ID == "" {
+		return &cmd, fmt.Errorf("command has no ID")
+	}
+	handler, ok := adminCommands[cmd.Command]
+	if !ok {
+		return &cmd, fmt.Errorf("unknown command %q", cmd.Command)
+	}
+	return &cmd, handler(arena, sourceAdmin, cmd.Data)
+}
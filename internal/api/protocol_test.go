@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/natesales/bunnyfms/internal/field"
+)
+
+func TestRunCommand(t *testing.T) {
+	arena, err := field.NewArena(field.Config{
+		AutoDuration:   15 * time.Second,
+		TeleopDuration: 2*time.Minute + 15*time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		command string
+		id      string
+		err     string
+	}{
+		{"ping", `{"v": 1, "id": "1", "command": "ping"}`, "1", ""},
+		{"arguments", `{"v": 1, "id": "2", "command": "update_alliances", "data": {"alliances": {"R1": 254, "B1": 971}}}`, "2", ""},
+		{"invalid json", `{"v": 1, "id": `, "", "invalid command: unexpected end of JSON input"},
+		{"old version", `{"message": "start"}`, "", "unsupported protocol version 0, expected 1"},
+		{"no id", `{"v": 1, "command": "start"}`, "", "command has no ID"},
+		{"unknown command", `{"v": 1, "id": "3", "command": "launch"}`, "3", `unknown command "launch"`},
+		{"unknown argument", `{"v": 1, "id": "4", "command": "stop", "data": {"now": true}}`, "4", `invalid arguments: json: unknown field "now"`},
+		{"bad argument", `{"v": 1, "id": "5", "command": "robot_mode", "data": {"alliance_station": "R1", "mode": "fly"}}`, "5", `invalid arguments: unknown robot mode "fly"`},
+		{"unknown station", `{"v": 1, "id": "6", "command": "estop", "data": {"alliance_station": "R9"}}`, "6", `unknown alliance station "R9"`},
+		{"not ready", `{"v": 1, "id": "7", "command": "start"}`, "7", "match Practice isn't ready to start (B1: DS not linked; B2: no team; B3: no team; R1: DS not linked; R2: no team; R3: no team)"},
+		{"not running", `{"v": 1, "id": "8", "command": "stop"}`, "8", "no match is running"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, err := runCommand(arena, []byte(tc.command))
+			r := newResult("", err)
+			if cmd != nil {
+				r = newResult(cmd.ID, err)
+			}
+			if r.ID != tc.id || r.OK != (tc.err == "") || r.Error != tc.err {
+				t.Fatalf("got %+v, want id %q and error %q", r, tc.id, tc.err)
+			}
+		})
+	}
+}
+
+func TestReplyEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		reply interface{}
+		json  string
+	}{
+		{newResult("1", nil), `{"v":1,"type":"result","id":"1","ok":true}`},
+		{newResult("2", errors.New("not ready")), `{"v":1,"type":"result","id":"2","ok":false,"error":"not ready"}`},
+		{newStateMessage(map[string]interface{}{"running": false}), `{"v":1,"type":"state","state":{"running":false}}`},
+	} {
+		data, err := json.Marshal(tc.reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.json {
+			t.Errorf("got %s, want %s", data, tc.json)
+		}
+	}
+}
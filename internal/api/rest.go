@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bunnyfms/internal/field"
+)
+
+// sourceREST is the source of commands from the REST API
+const sourceREST = "rest"
+
+// restRoute maps a REST endpoint to an admin command. The command's arguments are the JSON request body, or the body
+// under bodyKey if it is set, with the path parameters added as strings.
+type restRoute struct {
+	method  string
+	path    string
+	command string
+	bodyKey string
+}
+
+// restRoutes mirror the admin websocket commands
+var restRoutes = []restRoute{
+	{fiber.MethodPost, "/match/start", "start", ""},
+	{fiber.MethodPost, "/match/stop", "stop", ""},
+	{fiber.MethodPost, "/match/pause", "pause", ""},
+	{fiber.MethodPost, "/match/resume", "resume", ""},
+	{fiber.MethodPut, "/match", "match", "match"},
+	{fiber.MethodPost, "/timeout", "timeout", ""},
+	{fiber.MethodDelete, "/timeout", "cancel_timeout", ""},
+	{fiber.MethodPost, "/field_test/start", "field_test_start", ""},
+	{fiber.MethodPost, "/field_test/stop", "field_test_stop", ""},
+	{fiber.MethodPut, "/robot_mode/:alliance_station", "robot_mode", ""},
+	{fiber.MethodPost, "/estop/:alliance_station", "estop", ""},
+	{fiber.MethodDelete, "/estop/:alliance_station", "clear_estop", ""},
+	{fiber.MethodPost, "/astop/:alliance_station", "astop", ""},
+	{fiber.MethodPut, "/bypass/:alliance_station", "bypass", ""},
+	{fiber.MethodPut, "/bypass_alliance/:alliance", "bypass_alliance", ""},
+	{fiber.MethodPut, "/alliances", "update_alliances", "alliances"},
+	{fiber.MethodDelete, "/alliances", "reset_alliances", ""},
+	{fiber.MethodPut, "/game_data/:alliance", "game_data", ""},
+	{fiber.MethodPut, "/speed", "speed", ""},
+	{fiber.MethodPost, "/ds/reconnect", "ds_reconnect", ""},
+	{fiber.MethodPost, "/sounds/test", "test_sounds", ""},
+}
+
+// restResult is the reply to a REST command, with the reason it failed if it isn't ok
+type restResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// sendJSON replies with v encoded as JSON
+func sendJSON(c *fiber.Ctx, status int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.Status(status).Type("json")
+	return c.Send(body)
+}
+
+// restArgs builds a command's arguments from a REST request
+func restArgs(c *fiber.Ctx, route restRoute) (json.RawMessage, error) {
+	args := map[string]json.RawMessage{}
+	if body := c.Body(); len(body) > 0 {
+		if route.bodyKey != "" {
+			if !json.Valid(body) {
+				return nil, &field.ArgumentError{Err: errors.New("invalid request body: not valid JSON")}
+			}
+			args[route.bodyKey] = body
+		} else if err := json.Unmarshal(body, &args); err != nil {
+			return nil, &field.ArgumentError{Err: fmt.Errorf("invalid request body: %v", err)}
+		}
+	}
+	for _, param := range c.Route().Params {
+		value, err := json.Marshal(c.Params(param))
+		if err != nil {
+			return nil, err
+		}
+		args[param] = value
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(args)
+}
+
+// restStatus is the HTTP status for a failed command: 400 for invalid arguments and 409 when the field refused it
+func restStatus(err error) int {
+	var argErr *field.ArgumentError
+	if errors.As(err, &argErr) {
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusConflict
+}
+
+// setupREST adds the JSON REST API to router
+func setupREST(router fiber.Router, arena *field.Arena, h *hub) {
+	router.Get("/state", func(c *fiber.Ctx) error {
+		return sendJSON(c, fiber.StatusOK, arena.State())
+	})
+
+	for _, route := range restRoutes {
+		route := route
+		handler := adminCommands[route.command]
+		router.Add(route.method, route.path, func(c *fiber.Ctx) error {
+			data, err := restArgs(c, route)
+			if err == nil {
+				log.Debugf("REST command %s: %s", route.command, data)
+				err = handler(arena, sourceREST, data)
+			}
+			if err != nil {
+				log.Warnf("REST command %s failed: %v", route.command, err)
+				return sendJSON(c, restStatus(err), restResult{OK: false, Error: err.Error()})
+			}
+			h.notify()
+			return sendJSON(c, fiber.StatusOK, restResult{OK: true})
+		})
+	}
+}
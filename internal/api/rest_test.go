@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/natesales/bunnyfms/internal/field"
+)
+
+func TestREST(t *testing.T) {
+	arena, err := field.NewArena(field.Config{
+		AutoDuration:   15 * time.Second,
+		TeleopDuration: 2*time.Minute + 15*time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	setupREST(app.Group("/api"), arena, newHub(arena))
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		err    string
+	}{
+		{"alliances", "PUT", "/api/alliances", `{"R1": 254, "B1": 971}`, 200, ""},
+		{"bad team", "PUT", "/api/alliances", `{"R1": 99999}`, 400, "team number 99999 in R1 is outside 0 to 25599"},
+		{"invalid alliances body", "PUT", "/api/alliances", `{"R1": `, 400, "invalid request body: not valid JSON"},
+		{"bypass", "PUT", "/api/bypass/R2", `{"bypass": true}`, 200, ""},
+		{"bypass unknown station", "PUT", "/api/bypass/R9", `{"bypass": true}`, 400, `unknown alliance station "R9"`},
+		{"unknown argument", "POST", "/api/match/stop", `{"now": true}`, 400, `invalid arguments: json: unknown field "now"`},
+		{"invalid body", "POST", "/api/match/start", `{`, 400, "invalid request body: unexpected end of JSON input"},
+		{"stop without a match", "POST", "/api/match/stop", "", 409, "no match is running"},
+		{"bad timeout", "POST", "/api/timeout", `{"duration": "-1m"}`, 400, "timeout duration -1m0s isn't positive"},
+		{"estop", "POST", "/api/estop/B1", "", 200, ""},
+		{"change estopped team", "PUT", "/api/alliances", `{"B1": 1678}`, 409, "clear the e-stops of B1 before changing their teams"},
+		{"clear estop unknown station", "DELETE", "/api/estop/B4", "", 400, `unknown alliance station "B4"`},
+		{"clear estop", "DELETE", "/api/estop/B1", "", 200, ""},
+		{"timeout", "POST", "/api/timeout", `{"duration": "6m"}`, 200, ""},
+		{"cancel timeout", "DELETE", "/api/timeout", "", 200, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reply struct {
+				OK    bool   `json:"ok"`
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status || reply.OK != (tc.err == "") || reply.Error != tc.err {
+				t.Errorf("got %d %+v, want %d with error %q", resp.StatusCode, reply, tc.status, tc.err)
+			}
+		})
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/state", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	if state["state"] != "PreMatch" || state["alliances"].(map[string]interface{})["B1"] != 971.0 {
+		t.Errorf("unexpected state %v", state)
+	}
+	if bypass := state["bypass"].(map[string]interface{}); bypass["R2"] != true {
+		t.Errorf("R2 isn't bypassed: %v", bypass)
+	}
+
+	estops := state["estops"].([]interface{})
+	if len(estops) != 1 {
+		t.Fatalf("got e-stops %v", estops)
+	}
+	if estop := estops[0].(map[string]interface{}); estop["source"] != "rest" || estop["cleared_by"] != "rest" {
+		t.Errorf("REST e-stop isn't attributed to the REST API: %v", estop)
+	}
+}
@@ -0,0 +1,140 @@
+// Package clock provides the time source for match timers, so matches can be fast-forwarded, paused and sped up.
+package clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxSpeed is the fastest a scaled clock may run, relative to its base clock
+const MaxSpeed = 100
+
+// Clock tells the time
+type Clock interface {
+	Now() time.Time
+}
+
+type system struct{}
+
+func (system) Now() time.Time {
+	return time.Now()
+}
+
+// System is the wall clock
+var System Clock = system{}
+
+// Manual is a clock that only moves when it is advanced, for tests
+type Manual struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManual creates a manual clock stopped at the given time
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+// Now gets the current time of the clock
+func (c *Manual) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward
+func (c *Manual) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Scaled is a clock that runs at a multiple of its base clock's speed and can be paused
+type Scaled struct {
+	mu       sync.Mutex
+	base     Clock
+	speed    float64
+	paused   bool
+	baseMark time.Time // Base time when the speed or pause state last changed
+	mark     time.Time // Scaled time when the speed or pause state last changed
+}
+
+// NewScaled creates a running clock that starts at the base clock's current time
+func NewScaled(base Clock, speed float64) (*Scaled, error) {
+	if err := validateSpeed(speed); err != nil {
+		return nil, err
+	}
+	now := base.Now()
+	return &Scaled{base: base, speed: speed, baseMark: now, mark: now}, nil
+}
+
+func validateSpeed(speed float64) error {
+	if !(speed > 0 && speed <= MaxSpeed) {
+		return fmt.Errorf("clock speed %g is outside 0 to %d", speed, MaxSpeed)
+	}
+	return nil
+}
+
+// now gets the scaled time. Callers must hold c.mu.
+func (c *Scaled) now() time.Time {
+	if c.paused {
+		return c.mark
+	}
+	elapsed := c.base.Now().Sub(c.baseMark)
+	return c.mark.Add(time.Duration(float64(elapsed) * c.speed))
+}
+
+// rebase records the current time so the speed or pause state can change without the clock jumping. Callers must hold c.mu.
+func (c *Scaled) rebase() {
+	c.mark = c.now()
+	c.baseMark = c.base.Now()
+}
+
+// Now gets the current time of the clock
+func (c *Scaled) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+// Speed gets how many times faster than its base the clock runs
+func (c *Scaled) Speed() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.speed
+}
+
+// SetSpeed changes how many times faster than its base the clock runs
+func (c *Scaled) SetSpeed(speed float64) error {
+	if err := validateSpeed(speed); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebase()
+	c.speed = speed
+	return nil
+}
+
+// Pause stops the clock until it is resumed
+func (c *Scaled) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebase()
+	c.paused = true
+}
+
+// Resume restarts a paused clock from where it stopped
+func (c *Scaled) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebase()
+	c.paused = false
+}
+
+// Paused checks if the clock is paused
+func (c *Scaled) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
@@ -0,0 +1,66 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScaled(t *testing.T) {
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	base := NewManual(start)
+	c, err := NewScaled(base, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(want time.Duration) {
+		t.Helper()
+		if got := c.Now().Sub(start); got != want {
+			t.Fatalf("got %s since start, want %s", got, want)
+		}
+	}
+
+	base.Advance(time.Second)
+	expect(time.Second)
+
+	if err := c.SetSpeed(10); err != nil {
+		t.Fatal(err)
+	}
+	base.Advance(time.Second)
+	expect(11 * time.Second)
+
+	c.Pause()
+	if !c.Paused() {
+		t.Fatal("clock isn't paused")
+	}
+	base.Advance(time.Minute)
+	expect(11 * time.Second)
+
+	// Changing speed while paused must not move the clock
+	if err := c.SetSpeed(2); err != nil {
+		t.Fatal(err)
+	}
+	expect(11 * time.Second)
+
+	c.Resume()
+	base.Advance(500 * time.Millisecond)
+	expect(12 * time.Second)
+}
+
+func TestScaledSpeedLimits(t *testing.T) {
+	for _, speed := range []float64{0, -1, MaxSpeed + 1} {
+		if _, err := NewScaled(System, speed); err == nil {
+			t.Errorf("accepted speed %g", speed)
+		}
+	}
+	c, err := NewScaled(System, MaxSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetSpeed(0); err == nil {
+		t.Error("accepted speed 0")
+	}
+	if c.Speed() != MaxSpeed {
+		t.Errorf("got speed %g after a rejected change", c.Speed())
+	}
+}
@@ -0,0 +1,58 @@
+package driverstation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const controlPacketBytes = 22
+
+// ControlPacket is a decoded control packet, as seen by a driver station
+type ControlPacket struct {
+	Sequence         uint16
+	Test             bool
+	Auto             bool
+	Enabled          bool
+	Estop            bool
+	Astop            bool
+	Station          byte
+	MatchType        byte
+	MatchNumber      int
+	Replay           int
+	SecondsRemaining int
+	Time             time.Time // FMS wall clock time, which carries no time zone so is decoded as UTC
+}
+
+func (p ControlPacket) String() string {
+	mode := "teleop"
+	if p.Test {
+		mode = "test"
+	} else if p.Auto {
+		mode = "auto"
+	}
+	return fmt.Sprintf("#%d station=%d %s enabled=%v astop=%v estop=%v match=%d/%d/%d remaining=%ds",
+		p.Sequence, p.Station, mode, p.Enabled, p.Astop, p.Estop, p.MatchType, p.MatchNumber, p.Replay, p.SecondsRemaining)
+}
+
+// DecodeControlPacket parses a control packet sent by the FMS
+func DecodeControlPacket(data []byte) (ControlPacket, error) {
+	if len(data) < controlPacketBytes {
+		return ControlPacket{}, fmt.Errorf("control packet length %d is shorter than %d bytes", len(data), controlPacketBytes)
+	}
+	return ControlPacket{
+		Sequence:         binary.BigEndian.Uint16(data[0:2]),
+		Test:             data[3]&0x01 != 0,
+		Auto:             data[3]&0x02 != 0,
+		Enabled:          data[3]&0x04 != 0,
+		Astop:            data[3]&0x40 != 0,
+		Estop:            data[3]&0x80 != 0,
+		Station:          data[5],
+		MatchType:        data[6],
+		MatchNumber:      int(binary.BigEndian.Uint16(data[7:9])),
+		Replay:           int(data[9]),
+		SecondsRemaining: int(binary.BigEndian.Uint16(data[20:22])),
+		Time: time.Date(int(data[19])+1900, time.Month(data[18]), int(data[17]),
+			int(data[16]), int(data[15]), int(data[14]), int(binary.BigEndian.Uint32(data[10:14]))*1000, time.UTC),
+	}, nil
+}
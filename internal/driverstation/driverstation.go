@@ -1,46 +1,231 @@
 package driverstation
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bunnyfms/internal/clock"
 )
 
 // FMS uses 1121 for sending UDP packets, and FMS Lite uses 1120. Using 1121
 // seems to work just fine and doesn't prompt to let FMS take control.
 const (
-	driverStationTcpListenPort     = 1750
-	driverStationUdpSendPort       = 1121
-	driverStationUdpReceivePort    = 1160
-	driverStationTcpLinkTimeoutSec = 5
-	driverStationUdpLinkTimeoutSec = 1
-	maxTcpPacketBytes              = 4096
-	fmsIP                          = "10.0.100.5" // Hardcoded into the DS
+	DefaultTcpListenPort  = 1750
+	DefaultUdpSendPort    = 1121
+	DefaultUdpReceivePort = 1160
+	maxTcpPacketBytes     = 4096
+	MaxGameDataLength     = 255 // Game data is sent after a single byte size
 )
 
-var (
-	udpConn     *net.UDPConn
-	tcpListener net.Listener
+// Default timings, matching the official FMS
+const (
+	DefaultControlPacketInterval = 250 * time.Millisecond
+	DefaultUdpLinkTimeout        = 2 * time.Second
+	DefaultTcpLinkTimeout        = 5 * time.Second
+
+	// linkCheckPeriod is how often DS links are checked for timeouts
+	linkCheckPeriod = 100 * time.Millisecond
+
+	// tcpWriteTimeout is how long a TCP write to a DS may block. Writes happen with the field locked, so a stuck
+	// socket must not hold up the match.
+	tcpWriteTimeout = 100 * time.Millisecond
 )
 
+// resetDelay is how long Reset leaves communication stopped, long enough for every DS to notice and reconnect
+var resetDelay = 5 * time.Second
+
 type AllianceStation struct {
-	Team   int // Team number
+	Team   int  // Team number
+	Bypass bool // Station doesn't block the match from starting and its robot is never enabled
+	Estop  bool // Station stays e-stopped, even if its DS reconnects, until an operator clears it
 	DsConn *Conn
+	log    *TeamMatchLog // Packet log for the current match
+}
+
+// MatchType is the type of match reported to driver stations
+type MatchType byte
+
+const (
+	MatchTypeTest MatchType = iota
+	MatchTypePractice
+	MatchTypeQualification
+	MatchTypePlayoff
+)
+
+var matchTypeNames = map[MatchType]string{
+	MatchTypeTest:          "test",
+	MatchTypePractice:      "practice",
+	MatchTypeQualification: "qualification",
+	MatchTypePlayoff:       "playoff",
+}
+
+func (t MatchType) String() string {
+	if name, ok := matchTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MatchType(%d)", byte(t))
+}
+
+// MarshalText encodes a match type as its name
+func (t MatchType) MarshalText() ([]byte, error) {
+	if _, ok := matchTypeNames[t]; !ok {
+		return nil, fmt.Errorf("unknown match type %d", byte(t))
+	}
+	return []byte(t.String()), nil
 }
 
-var AllianceStations map[string]*AllianceStation
+// UnmarshalText decodes a match type from its name
+func (t *MatchType) UnmarshalText(text []byte) error {
+	for matchType, name := range matchTypeNames {
+		if name == string(text) {
+			*t = matchType
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown match type %q", text)
+}
 
-var commsQuit = make(chan bool)
+// RobotMode is the mode a driver station runs its robot in
+type RobotMode byte
+
+const (
+	RobotModeTeleop RobotMode = iota
+	RobotModeAuto
+	RobotModeTest
+)
+
+var robotModeNames = map[RobotMode]string{
+	RobotModeTeleop: "teleop",
+	RobotModeAuto:   "auto",
+	RobotModeTest:   "test",
+}
+
+func (m RobotMode) String() string {
+	if name, ok := robotModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("RobotMode(%d)", byte(m))
+}
+
+// MarshalText encodes a robot mode as its name
+func (m RobotMode) MarshalText() ([]byte, error) {
+	if _, ok := robotModeNames[m]; !ok {
+		return nil, fmt.Errorf("unknown robot mode %d", byte(m))
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText decodes a robot mode from its name
+func (m *RobotMode) UnmarshalText(text []byte) error {
+	for mode, name := range robotModeNames {
+		if name == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown robot mode %q", text)
+}
+
+// MatchStatus is the match information sent to driver stations in each control packet
+type MatchStatus struct {
+	Type             MatchType
+	Number           int           // Match number
+	Replay           int           // Replay number, starting at 1
+	SecondsRemaining int           // Seconds remaining in the current period
+	MatchTime        time.Duration // Time since the start of the match, or 0 if no match is running
+}
+
+// Config is the driver station communication configuration
+type Config struct {
+	FmsIP  string // Address to listen on, or empty to detect it from the field network
+	LogDir string // Directory to write per-team match logs to, or empty to disable them
+
+	ControlPacketInterval time.Duration // Time between control packets sent to each DS
+	UdpLinkTimeout        time.Duration // Time without a UDP status packet before a DS is considered unlinked
+	TcpLinkTimeout        time.Duration // Time without a TCP packet before a DS connection is closed
+
+	// Ports, which only need changing to run several FMSes on one machine
+	TcpListenPort  int // Port driver stations connect to over TCP
+	UdpReceivePort int // Port driver stations send status packets to
+	UdpSendPort    int // Port driver stations receive control packets on
+
+	Clock     clock.Clock // Clock to timestamp control packets with, or nil for the system clock
+	Transport Transport   // Connects driver stations without the network, for tests, or nil
+}
+
+// withDefaults fills in unset timings with the official FMS defaults
+func (cfg Config) withDefaults() Config {
+	if cfg.ControlPacketInterval <= 0 {
+		cfg.ControlPacketInterval = DefaultControlPacketInterval
+	}
+	if cfg.UdpLinkTimeout <= 0 {
+		cfg.UdpLinkTimeout = DefaultUdpLinkTimeout
+	}
+	if cfg.TcpLinkTimeout <= 0 {
+		cfg.TcpLinkTimeout = DefaultTcpLinkTimeout
+	}
+	if cfg.TcpListenPort == 0 {
+		cfg.TcpListenPort = DefaultTcpListenPort
+	}
+	if cfg.UdpReceivePort == 0 {
+		cfg.UdpReceivePort = DefaultUdpReceivePort
+	}
+	if cfg.UdpSendPort == 0 {
+		cfg.UdpSendPort = DefaultUdpSendPort
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.System
+	}
+	return cfg
+}
+
+// Comms owns the alliance stations and the sockets used to talk to their driver stations
+type Comms struct {
+	mu               sync.Mutex
+	cfg              Config
+	allianceStations map[string]*AllianceStation
+	udpConn          *net.UDPConn
+	tcpListener      net.Listener
+	quit             chan bool
+	started          bool // Start has succeeded, so Reset can restart communication
+	matchStatus      func() MatchStatus
+	gameData         map[string]string // Alliance prefix ("R" or "B") to the game data last sent to it
+}
+
+// NewComms creates a new driver station communication manager with no alliance stations.
+// matchStatus is called before each round of control packets and must not call back into Comms.
+func NewComms(cfg Config, matchStatus func() MatchStatus) *Comms {
+	c := &Comms{
+		cfg:              cfg.withDefaults(),
+		allianceStations: map[string]*AllianceStation{},
+		matchStatus:      matchStatus,
+		gameData:         map[string]string{},
+	}
+	if cfg.Transport != nil {
+		cfg.Transport.Bind(transportConnector{c})
+	}
+	return c
+}
 
 type Conn struct {
 	TeamId                    int
 	AllianceStation           string
 	Auto                      bool
+	Test                      bool // Robot is in test mode, for checkout outside a match
 	Enabled                   bool
 	Estop                     bool
+	Astop                     bool   // Robot is disabled for the rest of auto
+	WrongStation              bool   // DS has another team's address, so it's plugged into that team's station
+	PluggedInto               string // Station the DS is plugged into when WrongStation is set and that team is on the field
 	DsLinked                  bool
 	RadioLinked               bool
 	RobotLinked               bool
@@ -48,6 +233,21 @@ type Conn struct {
 	DsRobotTripTimeMs         int
 	MissedPacketCount         int
 	SecondsSinceLastRobotLink float64
+	CpuUsage                  float64 // Average robot CPU usage in percent
+	RamFreeBytes              uint32
+	DiskFreeBytes             uint32
+	CanUtilization            float64 // CAN bus utilization in percent
+	CanBusOff                 int
+	CanTxFull                 int
+	CanRxErrors               int
+	CanTxErrors               int
+	PdpCurrents               [pdpChannels]float64 // PDP channel currents in amps
+	Versions                  map[string]string    // Software and device name to version
+	LogMessages               []LogMessage         // Most recent robot messages, oldest first
+	UdpSourceIP               string               // Address the DS last sent a UDP status packet from
+	UdpPacketsReceived        int
+	UdpPacketsLost            int
+	lastUdpSequence           uint16
 	lastPacketTime            time.Time
 	lastRobotLinkedTime       time.Time
 	packetCount               int
@@ -58,47 +258,86 @@ type Conn struct {
 
 var allianceStationPositionMap = map[string]byte{"R1": 0, "R2": 1, "R3": 2, "B1": 3, "B2": 4, "B3": 5}
 
+// ValidateStation checks that an alliance station position is one of R1-R3 or B1-B3
+func ValidateStation(position string) error {
+	if _, ok := allianceStationPositionMap[position]; !ok {
+		return fmt.Errorf("unknown alliance station %q", position)
+	}
+	return nil
+}
+
 // Opens a UDP connection for communicating to the driver station.
-func newConn(teamId int, allianceStation string, tcpConn net.Conn) (*Conn, error) {
+func newConn(teamId int, allianceStation string, tcpConn net.Conn, udpSendPort int) (*Conn, error) {
 	ipAddress, _, err := net.SplitHostPort(tcpConn.RemoteAddr().String())
 	if err != nil {
 		return nil, err
 	}
 	log.Printf("Driver station for Team %d connected from %s\n", teamId, ipAddress)
 
-	dsUdpConn, err := net.Dial("udp4", fmt.Sprintf("%s:%d", ipAddress, driverStationUdpSendPort))
+	dsUdpConn, err := net.Dial("udp4", net.JoinHostPort(ipAddress, strconv.Itoa(udpSendPort)))
 	if err != nil {
 		return nil, err
 	}
 	return &Conn{TeamId: teamId, AllianceStation: allianceStation, tcpConn: tcpConn, udpConn: dsUdpConn}, nil
 }
 
-// Loops indefinitely to read packets and update connection status.
-func listenForDsUdpPackets() {
-	udpAddress, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf(":%d", driverStationUdpReceivePort))
-	var err error
-	udpConn, err = net.ListenUDP("udp4", udpAddress)
+// teamFromIP gets the team number from a 10.TE.AM.x address, or 0 if the address isn't a team address.
+func teamFromIP(ipAddress string) int {
+	ip := net.ParseIP(ipAddress).To4()
+	if ip == nil || ip[0] != 10 || ip[2] >= 100 {
+		return 0
+	}
+	return int(ip[1])*100 + int(ip[2])
+}
+
+// openUdp opens the socket driver stations send status packets to
+func (c *Comms) openUdp(fmsIP string) (*net.UDPConn, error) {
+	udpAddress, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(fmsIP, strconv.Itoa(c.cfg.UdpReceivePort)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve driver station UDP address: %v", err)
+	}
+	udpConn, err := net.ListenUDP("udp4", udpAddress)
 	if err != nil {
-		log.Warnf("Error opening driver station UDP socket: %v", err)
+		return nil, fmt.Errorf("unable to open driver station UDP socket on %s, check that the FMS IP is an address on this machine: %v", udpAddress, err)
 	}
-	log.Printf("Listening for driver stations on UDP port %d\n", driverStationUdpReceivePort)
+	c.mu.Lock()
+	c.udpConn = udpConn
+	c.mu.Unlock()
+	log.Printf("Listening for driver stations on UDP %s\n", udpAddress)
+	return udpConn, nil
+}
+
+// Loops indefinitely to read packets and update connection status.
+func (c *Comms) listenForDsUdpPackets(udpConn *net.UDPConn) {
+	defer udpConn.Close()
 
-	var data [50]byte
+	data := make([]byte, maxUdpPacketBytes)
 	for {
-		udpConn.Read(data[:])
+		n, sourceAddr, err := udpConn.ReadFromUDP(data)
+		if errors.Is(err, net.ErrClosed) {
+			return // Comms stopped
+		} else if err != nil {
+			log.Warnf("Error reading from driver station UDP socket: %v", err)
+			return
+		}
 
-		teamId := int(data[4])<<8 + int(data[5])
-		if teamId == 0 {
+		packet, err := decodeUdpStatusPacket(data[:n])
+		if err != nil {
+			log.Debugf("Ignoring UDP packet from %s: %v", sourceAddr, err)
+			continue
+		}
+		if packet.TeamId == 0 {
 			log.Debug("Ignoring packet from team 0")
 			continue
 		}
-		log.Debugf("Packet from team %d", teamId)
+		log.Debugf("Packet from team %d", packet.TeamId)
 
+		c.mu.Lock()
 		// Assign connection if it doesn't already exist
 		var dsConn *Conn
-		for _, allianceStation := range AllianceStations {
-			if allianceStation != nil && allianceStation.Team == teamId {
-				log.Debugf("Assigned driver station connection to team %d", teamId)
+		for _, allianceStation := range c.allianceStations {
+			if allianceStation != nil && allianceStation.Team == packet.TeamId {
+				log.Debugf("Assigned driver station connection to team %d", packet.TeamId)
 				dsConn = allianceStation.DsConn
 				break
 			}
@@ -107,35 +346,61 @@ func listenForDsUdpPackets() {
 		if dsConn != nil {
 			dsConn.DsLinked = true
 			dsConn.lastPacketTime = time.Now()
+			dsConn.trackUdpSequence(packet.Sequence)
+
+			if sourceIP := sourceAddr.IP.String(); sourceIP != dsConn.UdpSourceIP {
+				dsConn.UdpSourceIP = sourceIP
+				dsConn.WrongStation, dsConn.PluggedInto = c.wrongStation(sourceIP, dsConn.TeamId)
+			}
 
-			dsConn.RadioLinked = data[3]&0x10 != 0
-			dsConn.RobotLinked = data[3]&0x20 != 0
+			dsConn.RadioLinked = packet.RadioLinked
+			dsConn.RobotLinked = packet.RobotLinked
 			if dsConn.RobotLinked {
 				dsConn.lastRobotLinkedTime = time.Now()
-
-				// Robot battery voltage, stored as volts * 256.
-				dsConn.BatteryVoltage = float64(data[6]) + float64(data[7])/256
+				dsConn.BatteryVoltage = packet.BatteryVoltage
 			}
 		}
+		c.mu.Unlock()
 	}
 }
 
-// Sends a control packet to the Driver Station and checks for timeout conditions.
-func (dsConn *Conn) update(matchNumber int) error {
-	err := dsConn.sendControlPacket(matchNumber)
-	if err != nil {
-		return err
+// wrongStation checks a DS's IP address against the team assigned to it. A DS with another team's 10.TE.AM.x
+// address is plugged into that team's station, which is also returned if that team is on the field. Callers must
+// hold c.mu.
+func (c *Comms) wrongStation(ipAddress string, teamId int) (bool, string) {
+	stationTeamId := teamFromIP(ipAddress)
+	if stationTeamId == 0 || stationTeamId == teamId {
+		return false, ""
+	}
+	log.Infof("Team %d has IP %s belonging to team %d", teamId, ipAddress, stationTeamId)
+	for position, allianceStation := range c.allianceStations {
+		if allianceStation.Team == stationTeamId {
+			return true, position
+		}
 	}
+	return true, ""
+}
 
-	if time.Since(dsConn.lastPacketTime).Seconds() > driverStationUdpLinkTimeoutSec {
+// Checks for the DS link timing out.
+func (dsConn *Conn) checkLinkTimeout(udpLinkTimeout time.Duration) {
+	if time.Since(dsConn.lastPacketTime) > udpLinkTimeout {
 		dsConn.DsLinked = false
 		dsConn.RadioLinked = false
 		dsConn.RobotLinked = false
 		dsConn.BatteryVoltage = 0
 	}
 	dsConn.SecondsSinceLastRobotLink = time.Since(dsConn.lastRobotLinkedTime).Seconds()
+}
 
-	return nil
+// mode gets the mode the robot is in
+func (dsConn *Conn) mode() RobotMode {
+	switch {
+	case dsConn.Test:
+		return RobotModeTest
+	case dsConn.Auto:
+		return RobotModeAuto
+	}
+	return RobotModeTeleop
 }
 
 func (dsConn *Conn) close() {
@@ -148,7 +413,7 @@ func (dsConn *Conn) close() {
 }
 
 // Serializes the control information into a packet.
-func (dsConn *Conn) encodeControlPacket(matchNumber int) [22]byte {
+func (dsConn *Conn) encodeControlPacket(status MatchStatus, currentTime time.Time) [22]byte {
 	var packet [22]byte
 
 	// Packet number, stored big-endian in two bytes.
@@ -160,12 +425,18 @@ func (dsConn *Conn) encodeControlPacket(matchNumber int) [22]byte {
 
 	// Robot status byte.
 	packet[3] = 0
+	if dsConn.Test {
+		packet[3] |= 0x01
+	}
 	if dsConn.Auto {
 		packet[3] |= 0x02
 	}
 	if dsConn.Enabled {
 		packet[3] |= 0x04
 	}
+	if dsConn.Astop {
+		packet[3] |= 0x40
+	}
 	if dsConn.Estop {
 		packet[3] |= 0x80
 	}
@@ -176,15 +447,15 @@ func (dsConn *Conn) encodeControlPacket(matchNumber int) [22]byte {
 	// Alliance station.
 	packet[5] = allianceStationPositionMap[dsConn.AllianceStation]
 
-	packet[6] = 1 // Practice match
+	// Match type.
+	packet[6] = byte(status.Type)
 
 	// Match number
-	packet[7] = byte(matchNumber >> 8)
-	packet[8] = byte(matchNumber & 0xff)
-	packet[9] = 1 // Match repeat number
+	packet[7] = byte(status.Number >> 8)
+	packet[8] = byte(status.Number & 0xff)
+	packet[9] = byte(status.Replay) // Match repeat number
 
 	// Current time
-	currentTime := time.Now()
 	packet[10] = byte(((currentTime.Nanosecond() / 1000) >> 24) & 0xff)
 	packet[11] = byte(((currentTime.Nanosecond() / 1000) >> 16) & 0xff)
 	packet[12] = byte(((currentTime.Nanosecond() / 1000) >> 8) & 0xff)
@@ -196,27 +467,8 @@ func (dsConn *Conn) encodeControlPacket(matchNumber int) [22]byte {
 	packet[18] = byte(currentTime.Month())
 	packet[19] = byte(currentTime.Year() - 1900)
 
-	// Remaining number of seconds in match.
-	matchSecondsRemaining := 15
-	//switch arena.MatchState {
-	//case PreMatch:
-	//	fallthrough
-	//case TimeoutActive:
-	//	fallthrough
-	//case PostTimeout:
-	//	matchSecondsRemaining = game.MatchTiming.AutoDurationSec
-	//case StartMatch:
-	//	fallthrough
-	//case AutoPeriod:
-	//	matchSecondsRemaining = game.MatchTiming.AutoDurationSec - int(arena.MatchTimeSec())
-	//case PausePeriod:
-	//	matchSecondsRemaining = game.MatchTiming.TeleopDurationSec
-	//case TeleopPeriod:
-	//	matchSecondsRemaining = game.MatchTiming.AutoDurationSec + game.MatchTiming.TeleopDurationSec +
-	//		game.MatchTiming.PauseDurationSec - int(arena.MatchTimeSec())
-	//default:
-	//	matchSecondsRemaining = 0
-	//}
+	// Remaining number of seconds in the current period.
+	matchSecondsRemaining := status.SecondsRemaining
 	packet[20] = byte(matchSecondsRemaining >> 8 & 0xff)
 	packet[21] = byte(matchSecondsRemaining & 0xff)
 
@@ -227,8 +479,8 @@ func (dsConn *Conn) encodeControlPacket(matchNumber int) [22]byte {
 }
 
 // Builds and sends the next control packet to the Driver Station.
-func (dsConn *Conn) sendControlPacket(matchNumber int) error {
-	packet := dsConn.encodeControlPacket(matchNumber)
+func (dsConn *Conn) sendControlPacket(status MatchStatus, currentTime time.Time) error {
+	packet := dsConn.encodeControlPacket(status, currentTime)
 	if dsConn.udpConn != nil {
 		_, err := dsConn.udpConn.Write(packet[:])
 		if err != nil {
@@ -239,57 +491,53 @@ func (dsConn *Conn) sendControlPacket(matchNumber int) error {
 	return nil
 }
 
-// Deserializes a packet from the DS into a structure representing the DS/robot status.
-func (dsConn *Conn) decodeStatusPacket(data [36]byte) {
-	// Average DS-robot trip time in milliseconds.
-	dsConn.DsRobotTripTimeMs = int(data[1]) / 2
-
-	// Number of missed packets sent from the DS to the robot.
-	dsConn.MissedPacketCount = int(data[2]) - dsConn.missedPacketOffset
+// openTcp opens the socket driver stations connect to
+func (c *Comms) openTcp(fmsIP string) (net.Listener, error) {
+	tcpListener, err := net.Listen("tcp", net.JoinHostPort(fmsIP, strconv.Itoa(c.cfg.TcpListenPort)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open driver station TCP socket on %s: %v", fmsIP, err)
+	}
+	c.mu.Lock()
+	c.tcpListener = tcpListener
+	c.mu.Unlock()
+	log.Printf("Listening for driver stations on TCP %s\n", tcpListener.Addr())
+	return tcpListener, nil
 }
 
 // Listens for TCP connection requests to Cheesy Arena from driver stations.
-func listenForDriverStations() {
-	var err error
-	tcpListener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", fmsIP, driverStationTcpListenPort))
-	if err != nil {
-		log.Warnf("Error opening driver station TCP socket: %v", err)
-	}
+func (c *Comms) listenForDriverStations(tcpListener net.Listener) {
 	defer tcpListener.Close()
 
-	log.Printf("Listening for driver stations on TCP port %d\n", driverStationTcpListenPort)
 	for {
 		tcpConn, err := tcpListener.Accept()
-		if err != nil {
+		if errors.Is(err, net.ErrClosed) {
+			return // Comms stopped
+		} else if err != nil {
 			log.Warnf("Error accepting driver station connection: %v", err)
+			return
 		}
 
 		// Read the team number back and start tracking the driver station.
-		var packet [5]byte
-		if tcpConn == nil {
-			continue
-		}
-		_, err = tcpConn.Read(packet[:])
+		frames := NewFrameReader(tcpConn)
+		tcpConn.SetReadDeadline(time.Now().Add(c.cfg.TcpLinkTimeout))
+		teamId, err := frames.readTeamNumber()
 		if err != nil {
 			log.Println("Error reading initial packet: ", err.Error())
-			continue
-		}
-		if !(packet[0] == 0 && packet[1] == 3 && packet[2] == 24) {
-			log.Printf("Invalid initial packet received: %v", packet)
 			tcpConn.Close()
 			continue
 		}
-		teamId := int(packet[3])<<8 + int(packet[4])
 		log.Debugf("TCP packet from team %d", teamId)
 
-		// Check if the
+		// Check if the team is assigned to an alliance station
 		var assignedStation string
-		for position, allianceStation := range AllianceStations {
+		c.mu.Lock()
+		for position, allianceStation := range c.allianceStations {
 			if allianceStation.Team == teamId {
 				assignedStation = position
 				break
 			}
 		}
+		c.mu.Unlock()
 		if assignedStation == "" {
 			log.Warnf("Team %d shouldn't be on the field", teamId)
 			go func() {
@@ -300,191 +548,414 @@ func listenForDriverStations() {
 			continue
 		}
 
-		//// Read the team number from the IP address to check for a station mismatch.
-		//teamRe := regexp.MustCompile("\\d+\\.(\\d+)\\.(\\d+)\\.")
-		//ipAddress, _, err := net.SplitHostPort(tcpConn.RemoteAddr().String())
-		//teamDigits := teamRe.FindStringSubmatch(ipAddress)
-		//teamDigit1, _ := strconv.Atoi(teamDigits[1])
-		//teamDigit2, _ := strconv.Atoi(teamDigits[2])
-		//stationTeamId := teamDigit1*100 + teamDigit2
-		//log.Infof("Team %d has IP %d", teamId, stationTeamId)
+		// Check for a station mismatch by finding which team's cable the DS is plugged into from its IP address.
+		ipAddress, _, _ := net.SplitHostPort(tcpConn.RemoteAddr().String())
+		c.mu.Lock()
+		wrongStation, pluggedInto := c.wrongStation(ipAddress, teamId)
+		c.mu.Unlock()
 
-		var assignmentPacket [5]byte
-		assignmentPacket[0] = 0  // Packet size
-		assignmentPacket[1] = 3  // Packet size
-		assignmentPacket[2] = 25 // Packet type
 		log.Printf("Accepting connection from Team %d in station %s.", teamId, assignedStation)
-		assignmentPacket[3] = allianceStationPositionMap[assignedStation]
-		wrongStation := false
+		var wrongStationFlag byte
 		if wrongStation {
-			assignmentPacket[4] = 1
-		} else {
-			assignmentPacket[4] = 0
+			log.Warnf("Team %d is plugged into the wrong station (%s), should move to %s", teamId, ipAddress, assignedStation)
+			wrongStationFlag = 1
 		}
-		_, err = tcpConn.Write(assignmentPacket[:])
-		if err != nil {
+		if err := WriteFrame(tcpConn, FrameStationInfo, []byte{allianceStationPositionMap[assignedStation], wrongStationFlag}); err != nil {
 			log.Printf("Error sending driver station assignment packet: %v", err)
 			tcpConn.Close()
 			continue
 		}
 
-		dsConn, err := newConn(teamId, assignedStation, tcpConn)
+		dsConn, err := newConn(teamId, assignedStation, tcpConn, c.cfg.UdpSendPort)
 		if err != nil {
 			log.Printf("Error registering driver station connection: %v", err)
 			tcpConn.Close()
 			continue
 		}
-		if AllianceStations[assignedStation] == nil {
-			AllianceStations[assignedStation] = &AllianceStation{}
+		dsConn.WrongStation, dsConn.PluggedInto = wrongStation, pluggedInto
+		c.mu.Lock()
+		if c.allianceStations[assignedStation] == nil {
+			c.allianceStations[assignedStation] = &AllianceStation{}
+		}
+		dsConn.Estop = c.allianceStations[assignedStation].Estop
+		c.allianceStations[assignedStation].DsConn = dsConn
+		// A DS that reconnects after the game data went out would otherwise never get it
+		if gameData := c.gameData[assignedStation[:1]]; gameData != "" {
+			if err := dsConn.sendGameDataPacket(gameData); err != nil {
+				log.Warnf("Unable to resend game data to team %d: %v", teamId, err)
+			}
 		}
-		AllianceStations[assignedStation].DsConn = dsConn
+		c.mu.Unlock()
 
 		// Spin up a goroutine to handle further TCP communication with this driver station.
-		go dsConn.handleTcpConnection()
+		go c.handleTcpConnection(dsConn, frames)
 	}
 }
 
-func (dsConn *Conn) handleTcpConnection() {
-	buffer := make([]byte, maxTcpPacketBytes)
+func (c *Comms) handleTcpConnection(dsConn *Conn, frames *FrameReader) {
 	for {
-		dsConn.tcpConn.SetReadDeadline(time.Now().Add(time.Second * driverStationTcpLinkTimeoutSec))
-		_, err := dsConn.tcpConn.Read(buffer)
+		dsConn.tcpConn.SetReadDeadline(time.Now().Add(c.cfg.TcpLinkTimeout))
+		frameType, payload, err := frames.Next()
 		if err != nil {
 			log.Printf("Error reading from connection for Team %d: %v", dsConn.TeamId, err)
 			dsConn.close()
-			if AllianceStations[dsConn.AllianceStation] != nil {
-				AllianceStations[dsConn.AllianceStation].DsConn = nil
+			c.mu.Lock()
+			if allianceStation := c.allianceStations[dsConn.AllianceStation]; allianceStation != nil && allianceStation.DsConn == dsConn {
+				allianceStation.DsConn = nil
 			}
+			c.mu.Unlock()
 			break
 		}
 
-		packetType := int(buffer[2])
-		switch packetType {
-		case 28:
-			// DS keepalive packet; do nothing.
-		case 22:
-			// Robot status packet.
-			var statusPacket [36]byte
-			copy(statusPacket[:], buffer[2:38])
-			dsConn.decodeStatusPacket(statusPacket)
+		// Fetch the match time before locking, see sendDsPacket
+		matchTime := c.matchStatus().MatchTime
+
+		c.mu.Lock()
+		if err := dsConn.decodeTag(frameType, payload); err != nil {
+			log.Debugf("Unable to decode packet type %d from team %d: %v", frameType, dsConn.TeamId, err)
 		}
 
-		//// Log the packet if the match is in progress.
-		//matchTimeSec := arena.MatchTimeSec()
-		//if matchTimeSec > 0 && dsConn.log != nil {
-		//	dsConn.log.LogDsPacket(matchTimeSec, packetType, dsConn)
-		//}
+		// Log the packet if the match is in progress.
+		if allianceStation := c.allianceStations[dsConn.AllianceStation]; matchTime > 0 && allianceStation != nil && allianceStation.log != nil {
+			if err := allianceStation.log.LogDsPacket(matchTime, int(frameType), dsConn); err != nil {
+				log.Warnf("Unable to log packet for team %d: %v", dsConn.TeamId, err)
+			}
+		}
+		c.mu.Unlock()
 	}
 }
 
 // Sends a TCP packet containing the given game data to the driver station.
-//func (dsConn *Conn) sendGameDataPacket(gameData string) error {
-//	byteData := []byte(gameData)
-//	size := len(byteData)
-//	packet := make([]byte, size+4)
-//
-//	packet[0] = 0              // Packet size
-//	packet[1] = byte(size + 2) // Packet size
-//	packet[2] = 28             // Packet type
-//	packet[3] = byte(size)     // Data size
-//
-//	// Fill the rest of the packet with the data.
-//	for i, character := range byteData {
-//		packet[i+4] = character
-//	}
-//
-//	if dsConn.tcpConn != nil {
-//		_, err := dsConn.tcpConn.Write(packet)
-//		return err
-//	}
-//	return nil
-//}
-
-func sendDsPacket(matchNumber int) {
-	for _, allianceStation := range AllianceStations {
+func (dsConn *Conn) sendGameDataPacket(gameData string) error {
+	if dsConn.tcpConn != nil {
+		if err := dsConn.tcpConn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout)); err != nil {
+			return err
+		}
+		return WriteFrame(dsConn.tcpConn, FrameGameData, append([]byte{byte(len(gameData))}, gameData...))
+	}
+	return nil
+}
+
+func (c *Comms) sendDsPacket() {
+	// Fetch the match status before locking so the field is free to call into Comms while it holds its own lock
+	status := c.matchStatus()
+	now := c.cfg.Clock.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, allianceStation := range c.allianceStations {
 		if allianceStation.DsConn == nil {
 			continue // DS hasn't been picked up by the FMS yet
 		}
-		log.Printf("Sending to %d", allianceStation.DsConn.TeamId)
-		dsConn := allianceStation.DsConn
-		if dsConn != nil {
-			err := dsConn.update(matchNumber)
-			if err != nil {
-				log.Printf("Unable to send driver station packet for team %d", allianceStation.DsConn.TeamId)
-			}
+		log.Debugf("Sending to %d", allianceStation.DsConn.TeamId)
+		if err := allianceStation.DsConn.sendControlPacket(status, now); err != nil {
+			log.Printf("Unable to send driver station packet for team %d", allianceStation.DsConn.TeamId)
 		}
 	}
 }
 
-// StartComms starts drive station communication
-func StartComms() {
-	if AllianceStations == nil {
-		AllianceStations = map[string]*AllianceStation{}
+// checkLinkTimeouts marks driver stations that have stopped sending status packets as unlinked
+func (c *Comms) checkLinkTimeouts() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, allianceStation := range c.allianceStations {
+		if allianceStation.DsConn != nil {
+			allianceStation.DsConn.checkLinkTimeout(c.cfg.UdpLinkTimeout)
+		}
+	}
+}
+
+// supervise runs listen until comms are stopped, restarting it a second after it returns
+func supervise(quit chan bool, listen func()) {
+	for {
+		listen()
+		select {
+		case <-quit:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Start starts drive station communication
+func (c *Comms) Start() error {
+	fmsIP := c.cfg.FmsIP
+	if fmsIP == "" {
+		var err error
+		if fmsIP, err = detectFmsIP(); err != nil {
+			return err
+		}
+	} else if net.ParseIP(fmsIP) == nil {
+		return fmt.Errorf("invalid FMS IP %q", fmsIP)
+	}
+
+	// Open the sockets up front so an address that isn't on this machine fails here rather than on every retry
+	udpConn, err := c.openUdp(fmsIP)
+	if err != nil {
+		return err
+	}
+	tcpListener, err := c.openTcp(fmsIP)
+	if err != nil {
+		udpConn.Close()
+		c.mu.Lock()
+		c.udpConn = nil
+		c.mu.Unlock()
+		return err
 	}
 
-	log.Println("Initializing driver station communication")
-	dsPacketTicker := time.NewTicker(1000 * time.Millisecond)
+	c.mu.Lock()
+	quit := make(chan bool)
+	c.quit = quit
+	c.started = true
+	c.mu.Unlock()
+
+	log.Printf("Initializing driver station communication on %s", fmsIP)
 	go func() {
+		dsPacketTicker := time.NewTicker(c.cfg.ControlPacketInterval)
+		linkCheckTicker := time.NewTicker(linkCheckPeriod)
+		defer dsPacketTicker.Stop()
+		defer linkCheckTicker.Stop()
 		for {
 			select {
-			case <-commsQuit:
+			case <-quit:
 				return
 			case <-dsPacketTicker.C:
-				log.Debug("DS packet tick")
-				sendDsPacket(1000)
+				c.sendDsPacket()
+			case <-linkCheckTicker.C:
+				c.checkLinkTimeouts()
 			}
 		}
 	}()
 
-	go func() {
-		for {
-			select {
-			case <-commsQuit:
+	go supervise(quit, func() {
+		var err error
+		if udpConn == nil {
+			if udpConn, err = c.openUdp(fmsIP); err != nil {
+				log.Warn(err)
 				return
-			default:
-				listenForDsUdpPackets()
 			}
 		}
-	}()
-
-	go func() {
-		for {
-			select {
-			case <-commsQuit:
+		c.listenForDsUdpPackets(udpConn)
+		udpConn = nil
+	})
+	go supervise(quit, func() {
+		var err error
+		if tcpListener == nil {
+			if tcpListener, err = c.openTcp(fmsIP); err != nil {
+				log.Warn(err)
 				return
-			default:
-				listenForDriverStations()
 			}
 		}
-	}()
+		c.listenForDriverStations(tcpListener)
+		tcpListener = nil
+	})
+	return nil
 }
 
-// StopComms stops drive station communication
-func StopComms() {
+// Stop stops drive station communication
+func (c *Comms) Stop() {
 	log.Print("Stopping driver station communication")
-	commsQuit <- true
-	tcpListener.Close()
-	udpConn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.quit != nil {
+		close(c.quit)
+		c.quit = nil
+	}
+	if c.tcpListener != nil {
+		c.tcpListener.Close()
+		c.tcpListener = nil
+	}
+	if c.udpConn != nil {
+		c.udpConn.Close()
+		c.udpConn = nil
+	}
 }
 
-// ResetComms forces all DS to connect
-func ResetComms() {
+// Reset drops every DS connection and restarts communication, forcing all DS to reconnect
+func (c *Comms) Reset() error {
+	c.mu.Lock()
+	started := c.started
+	c.mu.Unlock()
+	if !started {
+		return errors.New("driver station communication isn't running")
+	}
+
 	log.Debug("Resetting driver station communication")
-	StopComms()
-	time.Sleep(5 * time.Second)
-	StartComms()
+	c.Stop()
+	c.mu.Lock()
+	c.closeAll()
+	c.mu.Unlock()
+	time.Sleep(resetDelay)
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("unable to restart driver station communication: %v", err)
+	}
+	return nil
 }
 
-// CloseAll closes all connections
-func CloseAll() {
-	for _, allianceStation := range AllianceStations {
+// OpenLogs starts a packet log for every team on the field for the given match
+func (c *Comms) OpenLogs(match string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cfg.LogDir == "" {
+		return
+	}
+	c.closeLogs()
+	for position, allianceStation := range c.allianceStations {
+		if allianceStation.Team == 0 {
+			continue
+		}
+		l, err := NewTeamMatchLog(c.cfg.LogDir, match, allianceStation.Team, position)
+		if err != nil {
+			log.Warnf("Unable to create match log for team %d: %v", allianceStation.Team, err)
+			continue
+		}
+		allianceStation.log = l
+	}
+}
+
+// CloseLogs finishes all packet logs for the current match
+func (c *Comms) CloseLogs() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closeLogs()
+}
+
+func (c *Comms) closeLogs() {
+	for _, allianceStation := range c.allianceStations {
+		if allianceStation.log == nil {
+			continue
+		}
+		if err := allianceStation.log.Close(); err != nil {
+			log.Warnf("Unable to close match log for team %d: %v", allianceStation.Team, err)
+		}
+		allianceStation.log = nil
+	}
+}
+
+// closeAll closes and detaches all connections
+func (c *Comms) closeAll() {
+	for _, allianceStation := range c.allianceStations {
 		if allianceStation.DsConn != nil {
-			allianceStation.DsConn.udpConn.Close()
-			allianceStation.DsConn.tcpConn.Close()
+			allianceStation.DsConn.close()
+			allianceStation.DsConn = nil
 		}
 	}
 }
 
+// SetTeams updates the team number of each given alliance station
+func (c *Comms) SetTeams(alliances map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for position, team := range alliances {
+		allianceStation := c.allianceStations[position]
+		if allianceStation == nil {
+			c.allianceStations[position] = &AllianceStation{Team: team}
+			continue
+		}
+		allianceStation.Team = team
+
+		// Drop the previous team's DS so it can't be enabled in the new team's place
+		if allianceStation.DsConn != nil && allianceStation.DsConn.TeamId != team {
+			log.Infof("Disconnecting team %d from %s, which now has team %d", allianceStation.DsConn.TeamId, position, team)
+			allianceStation.DsConn.close()
+			allianceStation.DsConn = nil
+		}
+	}
+}
+
+// TeamNumbers gets a map of alliance station position to team number
+func (c *Comms) TeamNumbers() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var o = make(map[string]int, len(c.allianceStations))
+	for position, allianceStation := range c.allianceStations {
+		o[position] = allianceStation.Team
+	}
+	return o
+}
+
+// SetBypass sets whether an alliance station is bypassed, disabling its robot straight away.
+// A station that stops being bypassed mid-match stays disabled until the next period starts.
+func (c *Comms) SetBypass(position string, bypass bool) error {
+	if err := ValidateStation(position); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.allianceStations[position] == nil {
+		c.allianceStations[position] = &AllianceStation{}
+	}
+	allianceStation := c.allianceStations[position]
+	allianceStation.Bypass = bypass
+	if bypass && allianceStation.DsConn != nil {
+		allianceStation.DsConn.Enabled = false
+	}
+	return nil
+}
+
+// Bypassed gets a map of alliance station position to whether it is bypassed
+func (c *Comms) Bypassed() map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	o := make(map[string]bool, len(allianceStationPositionMap))
+	for position := range allianceStationPositionMap {
+		o[position] = c.allianceStations[position] != nil && c.allianceStations[position].Bypass
+	}
+	return o
+}
+
+// Readiness gets the reasons each alliance station isn't ready for a match to start, which are empty for ready and bypassed stations
+func (c *Comms) Readiness() map[string][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	o := make(map[string][]string, len(allianceStationPositionMap))
+	for position := range allianceStationPositionMap {
+		allianceStation := c.allianceStations[position]
+		reasons := []string{}
+		switch {
+		case allianceStation != nil && allianceStation.Bypass:
+		case allianceStation == nil || allianceStation.Team == 0:
+			reasons = append(reasons, "no team")
+		case allianceStation.DsConn == nil || allianceStation.DsConn.TeamId != allianceStation.Team || !allianceStation.DsConn.DsLinked:
+			reasons = append(reasons, "DS not linked")
+		default:
+			dsConn := allianceStation.DsConn
+			if !dsConn.RadioLinked {
+				reasons = append(reasons, "radio not linked")
+			}
+			if !dsConn.RobotLinked {
+				reasons = append(reasons, "robot not linked")
+			}
+		}
+		if allianceStation != nil && !allianceStation.Bypass && allianceStation.Estop {
+			reasons = append(reasons, "e-stopped")
+		}
+		o[position] = reasons
+	}
+	return o
+}
+
+// ResetAlliances closes all connections and clears all alliance stations
+func (c *Comms) ResetAlliances() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closeAll()
+	c.closeLogs()
+	c.allianceStations = map[string]*AllianceStation{}
+}
+
 type DSStats struct {
 	LastPacket     string  `json:"last_packet"`
 	LastRobotLink  string  `json:"last_robot_link"`
@@ -493,6 +964,23 @@ type DSStats struct {
 	RobotLink      bool    `json:"robot_link"`
 	RadioLink      bool    `json:"radio_link"`
 	Estop          bool    `json:"estop"`
+	Astop          bool    `json:"astop"`
+	Enabled        bool    `json:"enabled"`
+	Mode           string  `json:"mode"`
+	WrongStation   bool    `json:"wrong_station,omitempty"` // DS is plugged into another team's station
+	PluggedInto    string  `json:"plugged_into,omitempty"`  // Station the DS is plugged into, if known
+	MoveTo         string  `json:"move_to,omitempty"`       // Station the DS should move to
+
+	UdpPacketLoss  float64              `json:"udp_packet_loss"`
+	TripTimeMs     int                  `json:"trip_time_ms"`
+	MissedPackets  int                  `json:"missed_packets"`
+	CpuUsage       float64              `json:"cpu_usage"`
+	RamFreeBytes   uint32               `json:"ram_free_bytes"`
+	DiskFreeBytes  uint32               `json:"disk_free_bytes"`
+	CanUtilization float64              `json:"can_utilization"`
+	PdpCurrents    [pdpChannels]float64 `json:"pdp_currents"`
+	Versions       map[string]string    `json:"versions"`
+	LogMessages    []LogMessage         `json:"log_messages"`
 }
 
 func roundTime(t time.Time) string {
@@ -504,10 +992,12 @@ func roundTime(t time.Time) string {
 }
 
 // ConnectionStats gets a map of alliance station to connection stats
-func ConnectionStats() map[string]*DSStats {
-	o := map[string]*DSStats{}
+func (c *Comms) ConnectionStats() map[string]*DSStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	for position, allianceStation := range AllianceStations {
+	o := map[string]*DSStats{}
+	for position, allianceStation := range c.allianceStations {
 		if allianceStation.DsConn != nil {
 			o[position] = &DSStats{
 				LastPacket:     roundTime(allianceStation.DsConn.lastPacketTime),
@@ -517,6 +1007,27 @@ func ConnectionStats() map[string]*DSStats {
 				RobotLink:      allianceStation.DsConn.RobotLinked,
 				RadioLink:      allianceStation.DsConn.RadioLinked,
 				Estop:          allianceStation.DsConn.Estop,
+				Astop:          allianceStation.DsConn.Astop,
+				Enabled:        allianceStation.DsConn.Enabled,
+				Mode:           allianceStation.DsConn.mode().String(),
+				UdpPacketLoss:  math.Round(allianceStation.DsConn.UdpPacketLoss()*10) / 10,
+				TripTimeMs:     allianceStation.DsConn.DsRobotTripTimeMs,
+				MissedPackets:  allianceStation.DsConn.MissedPacketCount,
+				CpuUsage:       math.Round(allianceStation.DsConn.CpuUsage*10) / 10,
+				RamFreeBytes:   allianceStation.DsConn.RamFreeBytes,
+				DiskFreeBytes:  allianceStation.DsConn.DiskFreeBytes,
+				CanUtilization: math.Round(allianceStation.DsConn.CanUtilization*10) / 10,
+				PdpCurrents:    allianceStation.DsConn.PdpCurrents,
+				Versions:       map[string]string{},
+				LogMessages:    append([]LogMessage{}, allianceStation.DsConn.LogMessages...),
+			}
+			for name, version := range allianceStation.DsConn.Versions {
+				o[position].Versions[name] = version
+			}
+			if allianceStation.DsConn.WrongStation {
+				o[position].WrongStation = true
+				o[position].PluggedInto = allianceStation.DsConn.PluggedInto
+				o[position].MoveTo = position
 			}
 		}
 	}
@@ -524,41 +1035,173 @@ func ConnectionStats() map[string]*DSStats {
 }
 
 // StartAuto starts autonomous
-func StartAuto() {
-	for _, allianceStation := range AllianceStations {
+func (c *Comms) StartAuto() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, allianceStation := range c.allianceStations {
 		if allianceStation.DsConn != nil {
 			allianceStation.DsConn.Auto = true
-			allianceStation.DsConn.Enabled = true
+			allianceStation.DsConn.Test = false
+			allianceStation.DsConn.Enabled = !allianceStation.Bypass && !allianceStation.Estop && !allianceStation.DsConn.Astop
 		}
 	}
 }
 
 // StartTeleop starts teleop
-func StartTeleop() {
-	for _, allianceStation := range AllianceStations {
+func (c *Comms) StartTeleop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, allianceStation := range c.allianceStations {
 		if allianceStation.DsConn != nil {
 			allianceStation.DsConn.Auto = false
-			allianceStation.DsConn.Enabled = true
+			allianceStation.DsConn.Test = false
+			allianceStation.DsConn.Astop = false
+			allianceStation.DsConn.Enabled = !allianceStation.Bypass && !allianceStation.Estop
 		}
 	}
 }
 
 // StopMatch stops the match
-func StopMatch() {
-	for _, allianceStation := range AllianceStations {
+func (c *Comms) StopMatch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, allianceStation := range c.allianceStations {
 		if allianceStation.DsConn != nil {
 			allianceStation.DsConn.Enabled = false
-			allianceStation.DsConn.Estop = false
+			allianceStation.DsConn.Test = false
+			allianceStation.DsConn.Astop = false
 		}
 	}
 }
 
-// Estop estops an alliance member
-func Estop(alliance string) {
-	if AllianceStations != nil && AllianceStations[alliance] != nil {
-		dsConn := AllianceStations[alliance].DsConn
-		if dsConn != nil {
-			dsConn.Estop = true
+// DisableAll disables every robot without ending the match, leaving e-stops in place
+func (c *Comms) DisableAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, allianceStation := range c.allianceStations {
+		if allianceStation.DsConn != nil {
+			allianceStation.DsConn.Enabled = false
+		}
+	}
+}
+
+// SendGameData sends game-specific data to every connected driver station on an alliance ("red" or "blue")
+func (c *Comms) SendGameData(alliance, gameData string) {
+	if alliance == "" {
+		return
+	}
+	prefix := strings.ToUpper(alliance[:1])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gameData[prefix] = gameData
+	for position, allianceStation := range c.allianceStations {
+		if allianceStation.DsConn == nil || !strings.HasPrefix(position, prefix) {
+			continue
+		}
+		if err := allianceStation.DsConn.sendGameDataPacket(gameData); err != nil {
+			log.Warnf("Unable to send game data to team %d: %v", allianceStation.DsConn.TeamId, err)
+		}
+	}
+}
+
+// SetRobotMode puts a single alliance member's robot into a mode and enables or disables it, leaving every other station untouched
+func (c *Comms) SetRobotMode(alliance string, mode RobotMode, enabled bool) error {
+	if _, ok := robotModeNames[mode]; !ok {
+		return fmt.Errorf("unknown robot mode %d", byte(mode))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	allianceStation := c.allianceStations[alliance]
+	if allianceStation == nil || allianceStation.DsConn == nil {
+		return fmt.Errorf("no driver station connected in %s", alliance)
+	}
+	if enabled && allianceStation.Bypass {
+		return fmt.Errorf("%s is bypassed", alliance)
+	}
+	if enabled && allianceStation.Estop {
+		return fmt.Errorf("%s is e-stopped", alliance)
+	}
+	dsConn := allianceStation.DsConn
+	dsConn.Auto = mode == RobotModeAuto
+	dsConn.Test = mode == RobotModeTest
+	dsConn.Enabled = enabled
+	return nil
+}
+
+// Astop disables an alliance member for the rest of auto; it is re-enabled when teleop starts
+func (c *Comms) Astop(alliance string) error {
+	if err := ValidateStation(alliance); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.allianceStations[alliance] == nil || c.allianceStations[alliance].DsConn == nil {
+		return fmt.Errorf("no driver station connected in %s", alliance)
+	}
+	dsConn := c.allianceStations[alliance].DsConn
+	dsConn.Astop = true
+	dsConn.Enabled = false
+	return nil
+}
+
+// Estop e-stops an alliance member until the e-stop is cleared, returning the team in the station
+func (c *Comms) Estop(alliance string) (int, error) {
+	if err := ValidateStation(alliance); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.allianceStations[alliance] == nil {
+		c.allianceStations[alliance] = &AllianceStation{}
+	}
+	allianceStation := c.allianceStations[alliance]
+	allianceStation.Estop = true
+	if allianceStation.DsConn != nil {
+		allianceStation.DsConn.Estop = true
+		allianceStation.DsConn.Enabled = false
+	}
+	return allianceStation.Team, nil
+}
+
+// ClearEstop clears an alliance member's e-stop
+func (c *Comms) ClearEstop(alliance string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	allianceStation := c.allianceStations[alliance]
+	if allianceStation == nil || !allianceStation.Estop {
+		return fmt.Errorf("%s isn't e-stopped", alliance)
+	}
+	allianceStation.Estop = false
+	if allianceStation.DsConn != nil {
+		allianceStation.DsConn.Estop = false
+	}
+	return nil
+}
+
+// Estopped gets the alliance stations that are e-stopped
+func (c *Comms) Estopped() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var o []string
+	for position, allianceStation := range c.allianceStations {
+		if allianceStation.Estop {
+			o = append(o, position)
 		}
 	}
+	sort.Strings(o)
+	return o
 }
@@ -0,0 +1,134 @@
+// Package dstest provides fake driver stations for testing the field without a network
+package dstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/natesales/bunnyfms/internal/driverstation"
+)
+
+// Transport is an in-memory driverstation.Transport. Set it as Config.Transport and attach fake driver stations to
+// it once teams are assigned.
+type Transport struct {
+	connector driverstation.Connector
+}
+
+// Bind implements driverstation.Transport
+func (t *Transport) Bind(connector driverstation.Connector) {
+	t.connector = connector
+}
+
+// Attach connects a fake driver station for the team assigned to a station, marking it linked
+func (t *Transport) Attach(allianceStation string) (*DriverStation, error) {
+	if t.connector == nil {
+		return nil, errors.New("transport isn't bound to driver station comms")
+	}
+	ds := &DriverStation{connector: t.connector, station: allianceStation}
+	if err := t.connector.Connect(allianceStation, &conn{write: ds.recordTcpFrames}, &conn{write: ds.recordControlPacket}); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// SendControlPackets sends a round of control packets to every driver station immediately
+func (t *Transport) SendControlPackets() {
+	t.connector.SendControlPackets()
+}
+
+// DriverStation stands in for a connected driver station, recording what the FMS sends it
+type DriverStation struct {
+	connector driverstation.Connector
+	station   string
+
+	mu             sync.Mutex
+	controlPackets []driverstation.ControlPacket
+	gameData       []string
+}
+
+// SetLinks sets the DS, radio and robot links the FMS sees for the fake DS
+func (d *DriverStation) SetLinks(ds, radio, robot bool) error {
+	return d.connector.SetLinks(d.station, ds, radio, robot)
+}
+
+// ControlPackets gets every control packet sent to the fake DS so far
+func (d *DriverStation) ControlPackets() []driverstation.ControlPacket {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]driverstation.ControlPacket(nil), d.controlPackets...)
+}
+
+// LastControlPacket gets the most recent control packet, or false if none have been sent
+func (d *DriverStation) LastControlPacket() (driverstation.ControlPacket, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.controlPackets) == 0 {
+		return driverstation.ControlPacket{}, false
+	}
+	return d.controlPackets[len(d.controlPackets)-1], true
+}
+
+// GameData gets every game-specific message sent to the fake DS so far
+func (d *DriverStation) GameData() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.gameData...)
+}
+
+func (d *DriverStation) recordControlPacket(data []byte) error {
+	packet, err := driverstation.DecodeControlPacket(data)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.controlPackets = append(d.controlPackets, packet)
+	return nil
+}
+
+func (d *DriverStation) recordTcpFrames(data []byte) error {
+	frames := driverstation.NewFrameReader(bytes.NewReader(data))
+	for {
+		frameType, payload, err := frames.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if frameType != driverstation.FrameGameData {
+			continue
+		}
+		if len(payload) < 1 || int(payload[0]) > len(payload)-1 {
+			return fmt.Errorf("malformed game data frame % x", payload)
+		}
+		d.mu.Lock()
+		d.gameData = append(d.gameData, string(payload[1:1+int(payload[0])]))
+		d.mu.Unlock()
+	}
+}
+
+// conn is a net.Conn that hands every write to a callback and never has anything to read
+type conn struct {
+	write func([]byte) error
+}
+
+func (c *conn) Read([]byte) (int, error) { return 0, errors.New("fake connection is write only") }
+
+func (c *conn) Write(b []byte) (int, error) {
+	if err := c.write(b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *conn) Close() error                     { return nil }
+func (c *conn) LocalAddr() net.Addr              { return &net.UDPAddr{} }
+func (c *conn) RemoteAddr() net.Addr             { return &net.UDPAddr{} }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
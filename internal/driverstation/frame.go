@@ -0,0 +1,78 @@
+package driverstation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Frame types sent from the FMS to the DS over TCP
+const (
+	FrameStationInfo = 0x19 // Station (uint8), wrong station (uint8)
+	FrameGameData    = 0x1c // Game data (uint8 size + string)
+)
+
+// FrameReader reassembles [uint16 length][type][payload] frames from a DS TCP stream, regardless of how
+// the frames are split across or coalesced into reads. Both directions of the stream use the same framing.
+type FrameReader struct {
+	reader *bufio.Reader
+}
+
+// NewFrameReader creates a frame reader for a TCP stream
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{reader: bufio.NewReaderSize(r, maxTcpPacketBytes)}
+}
+
+// Next reads the next non-empty frame, returning its type and payload.
+// A stream that ends partway through a frame returns io.ErrUnexpectedEOF.
+func (f *FrameReader) Next() (byte, []byte, error) {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(f.reader, header[:]); err != nil {
+			return 0, nil, err
+		}
+
+		length := int(binary.BigEndian.Uint16(header[:]))
+		if length == 0 {
+			continue // Empty frame
+		}
+		if length > maxTcpPacketBytes {
+			return 0, nil, fmt.Errorf("frame length %d exceeds maximum of %d bytes", length, maxTcpPacketBytes)
+		}
+
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(f.reader, frame); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, nil, err
+		}
+		return frame[0], frame[1:], nil
+	}
+}
+
+// readTeamNumber reads the initial team number frame a DS sends after connecting
+func (f *FrameReader) readTeamNumber() (int, error) {
+	frameType, payload, err := f.Next()
+	if err != nil {
+		return 0, err
+	}
+	if frameType != tagTeamNumber || len(payload) != 2 {
+		return 0, fmt.Errorf("invalid initial packet type %d with %d byte payload", frameType, len(payload))
+	}
+	return int(binary.BigEndian.Uint16(payload)), nil
+}
+
+// WriteFrame writes a single frame to a TCP stream
+func WriteFrame(w io.Writer, frameType byte, payload []byte) error {
+	if len(payload)+1 > maxTcpPacketBytes {
+		return fmt.Errorf("frame length %d exceeds maximum of %d bytes", len(payload)+1, maxTcpPacketBytes)
+	}
+	frame := make([]byte, 3+len(payload))
+	binary.BigEndian.PutUint16(frame[0:2], uint16(1+len(payload)))
+	frame[2] = frameType
+	copy(frame[3:], payload)
+	_, err := w.Write(frame)
+	return err
+}
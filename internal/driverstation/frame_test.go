@@ -0,0 +1,337 @@
+package driverstation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chunkedReader returns its data split into reads of the given sizes, like a TCP stream would
+type chunkedReader struct {
+	data   []byte
+	chunks []int
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := len(r.data)
+	if len(r.chunks) > 0 {
+		n, r.chunks = r.chunks[0], r.chunks[1:]
+		if n > len(r.data) {
+			n = len(r.data)
+		}
+	}
+	n = copy(p, r.data[:n])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+type frame struct {
+	frameType byte
+	payload   []byte
+}
+
+var (
+	// Team 254 connecting
+	teamNumberFrame = []byte{0x00, 0x03, 0x18, 0x00, 0xfe}
+	// Keepalive sent while the DS is idle
+	keepAliveFrame = []byte{0x00, 0x01, 0x1c}
+	// Robot status with 10 ms trip time, 3 lost packets, 12.5 V and 40% CAN utilization
+	statusFrame = []byte{0x00, 0x07, 0x16, 0x14, 0x03, 0x0c, 0x80, 0x00, 0x28}
+	// Robot printed "hi"
+	stdoutFrame = []byte{0x00, 0x09, 0x0c, 0x41, 0x20, 0x00, 0x00, 0x00, 0x01, 'h', 'i'}
+)
+
+func concat(frames ...[]byte) []byte {
+	return bytes.Join(frames, nil)
+}
+
+func TestFrameReader(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		stream []byte
+		chunks []int
+		frames []frame
+		err    error
+	}{
+		{
+			name:   "single frame",
+			stream: teamNumberFrame,
+			frames: []frame{{0x18, []byte{0x00, 0xfe}}},
+			err:    io.EOF,
+		},
+		{
+			name:   "coalesced frames",
+			stream: concat(teamNumberFrame, keepAliveFrame, statusFrame),
+			frames: []frame{
+				{0x18, []byte{0x00, 0xfe}},
+				{0x1c, []byte{}},
+				{0x16, statusFrame[3:]},
+			},
+			err: io.EOF,
+		},
+		{
+			name:   "frames split across reads",
+			stream: concat(statusFrame, stdoutFrame),
+			chunks: []int{1, 4, 7, 2, 1, 100},
+			frames: []frame{
+				{0x16, statusFrame[3:]},
+				{0x0c, stdoutFrame[3:]},
+			},
+			err: io.EOF,
+		},
+		{
+			name:   "one byte at a time",
+			stream: concat(keepAliveFrame, statusFrame),
+			chunks: []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+			frames: []frame{
+				{0x1c, []byte{}},
+				{0x16, statusFrame[3:]},
+			},
+			err: io.EOF,
+		},
+		{
+			name:   "empty frames are skipped",
+			stream: concat([]byte{0x00, 0x00}, keepAliveFrame, []byte{0x00, 0x00}),
+			frames: []frame{{0x1c, []byte{}}},
+			err:    io.EOF,
+		},
+		{
+			name:   "truncated payload",
+			stream: concat(keepAliveFrame, statusFrame[:5]),
+			frames: []frame{{0x1c, []byte{}}},
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "truncated header",
+			stream: concat(keepAliveFrame, []byte{0x00}),
+			frames: []frame{{0x1c, []byte{}}},
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "oversized frame",
+			stream: []byte{0xff, 0xff, 0x16},
+			err:    errors.New("frame length 65535 exceeds maximum of 4096 bytes"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewFrameReader(&chunkedReader{data: tc.stream, chunks: tc.chunks})
+			for i, want := range tc.frames {
+				frameType, payload, err := r.Next()
+				if err != nil {
+					t.Fatalf("frame %d: unexpected error %v", i, err)
+				}
+				if frameType != want.frameType || !bytes.Equal(payload, want.payload) {
+					t.Errorf("frame %d: got type %#x payload %v, want type %#x payload %v", i, frameType, payload, want.frameType, want.payload)
+				}
+			}
+			if _, _, err := r.Next(); err == nil || err.Error() != tc.err.Error() {
+				t.Errorf("got error %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	var stream bytes.Buffer
+	if err := WriteFrame(&stream, tagTeamNumber, []byte{0x00, 0xfe}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFrame(&stream, tagKeepAlive, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := concat(teamNumberFrame, keepAliveFrame); !bytes.Equal(stream.Bytes(), want) {
+		t.Errorf("got stream % x, want % x", stream.Bytes(), want)
+	}
+	if err := WriteFrame(&stream, tagStdout, make([]byte, maxTcpPacketBytes)); err == nil {
+		t.Error("wrote a frame longer than the maximum")
+	}
+}
+
+func TestReadTeamNumber(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		stream  []byte
+		team    int
+		invalid bool
+	}{
+		{name: "team number", stream: teamNumberFrame, team: 254},
+		{name: "team number split", stream: concat(teamNumberFrame[:2], teamNumberFrame[2:]), team: 254},
+		{name: "wrong frame type", stream: keepAliveFrame, invalid: true},
+		{name: "short payload", stream: []byte{0x00, 0x02, 0x18, 0x01}, invalid: true},
+		{name: "empty stream", stream: nil, invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			team, err := NewFrameReader(bytes.NewReader(tc.stream)).readTeamNumber()
+			if tc.invalid {
+				if err == nil {
+					t.Errorf("got team %d, want error", team)
+				}
+				return
+			}
+			if err != nil || team != tc.team {
+				t.Errorf("got team %d error %v, want team %d", team, err, tc.team)
+			}
+		})
+	}
+}
+
+func TestDecodeTag(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		stream    []byte
+		check     func(*Conn) bool
+		shortData bool
+	}{
+		{
+			name:   "robot status",
+			stream: statusFrame,
+			check: func(c *Conn) bool {
+				return c.DsRobotTripTimeMs == 10 && c.MissedPacketCount == 3 && c.CanUtilization == 40
+			},
+		},
+		{
+			name:   "stdout",
+			stream: stdoutFrame,
+			check: func(c *Conn) bool {
+				return len(c.LogMessages) == 1 && c.LogMessages[0].Type == "stdout" && c.LogMessages[0].Message == "hi"
+			},
+		},
+		{
+			name:   "cpu info",
+			stream: []byte{0x00, 0x12, 0x05, 0x01, 0x41, 0x20, 0, 0, 0x41, 0x20, 0, 0, 0, 0, 0, 0, 0x3f, 0x80, 0, 0},
+			check:  func(c *Conn) bool { return c.CpuUsage == 21 },
+		},
+		{
+			name:   "version info",
+			stream: []byte{0x00, 0x0b, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x02, 'D', 'S', 0x02, '2', '4'},
+			check:  func(c *Conn) bool { return c.Versions["DS"] == "24" },
+		},
+		{
+			name:   "pdp log",
+			stream: concat([]byte{0x00, 0x16, 0x08, 0x00, 0x02, 0x80}, make([]byte, 18)),
+			check:  func(c *Conn) bool { return c.PdpCurrents[0] == 1.25 && c.PdpCurrents[1] == 0 },
+		},
+		{
+			name:      "truncated cpu info",
+			stream:    []byte{0x00, 0x04, 0x05, 0x02, 0x41, 0x20},
+			check:     func(c *Conn) bool { return c.CpuUsage == 0 },
+			shortData: true,
+		},
+		{
+			name:      "truncated status",
+			stream:    []byte{0x00, 0x02, 0x16, 0x14},
+			check:     func(c *Conn) bool { return c.DsRobotTripTimeMs == 0 },
+			shortData: true,
+		},
+		{
+			name:   "unknown tag",
+			stream: []byte{0x00, 0x02, 0x7f, 0x01},
+			check:  func(c *Conn) bool { return true },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			frameType, payload, err := NewFrameReader(bytes.NewReader(tc.stream)).Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dsConn := &Conn{}
+			err = dsConn.decodeTag(frameType, payload)
+			if tc.shortData != (err == errShortPayload) {
+				t.Errorf("got error %v, short payload expected: %v", err, tc.shortData)
+			}
+			if !tc.check(dsConn) {
+				t.Errorf("unexpected connection state %+v", dsConn)
+			}
+		})
+	}
+}
+
+// readSession reads a DS to FMS TCP stream from a testdata file, with one TCP segment per line as hex and # comments
+func readSession(t *testing.T, path string) (stream []byte, segments []int) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		segment, err := hex.DecodeString(strings.Join(strings.Fields(line), ""))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		stream = append(stream, segment...)
+		segments = append(segments, len(segment))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return stream, segments
+}
+
+// replaySessionChecks are the expected results of replaying sessions in testdata. Sessions without checks only need
+// to frame and decode cleanly.
+var replaySessionChecks = map[string]func(*Conn) bool{
+	"session_254.hex": func(c *Conn) bool {
+		return c.TeamId == 254 &&
+			c.DsRobotTripTimeMs == 8 && c.MissedPacketCount == 4 &&
+			c.CpuUsage == 20 && c.RamFreeBytes == 123456789 && c.DiskFreeBytes == 987654 &&
+			c.Versions["FRC Driver Station"] == "24.0" && c.Versions["roboRIO Image"] == "FRC_roboRIO_2024_v2.1" &&
+			len(c.LogMessages) == 3 && c.LogMessages[0].Message == "Radio connected" &&
+			c.LogMessages[2].Type == "error" && c.LogMessages[2].Code == -1
+	},
+}
+
+func TestReplaySessions(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no sessions in testdata")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			stream, segments := readSession(t, path)
+
+			// Replay the stream as it arrived and as one large read, which must give the same frames
+			for _, chunks := range [][]int{segments, nil} {
+				frames := NewFrameReader(&chunkedReader{data: stream, chunks: chunks})
+				team, err := frames.readTeamNumber()
+				if err != nil {
+					t.Fatal(err)
+				}
+				dsConn := &Conn{TeamId: team}
+				for {
+					frameType, payload, err := frames.Next()
+					if err == io.EOF {
+						break
+					} else if err != nil {
+						t.Fatal(err)
+					}
+					if err := dsConn.decodeTag(frameType, payload); err != nil {
+						t.Errorf("unable to decode frame type %#x with %d byte payload: %v", frameType, len(payload), err)
+					}
+				}
+				if check := replaySessionChecks[filepath.Base(path)]; check != nil && !check(dsConn) {
+					t.Errorf("unexpected connection state %+v", dsConn)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package driverstation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var teamMatchLogHeader = []string{
+	"time", "match_time_sec", "packet_type", "team", "alliance_station",
+	"enabled", "auto", "estop", "ds_linked", "radio_linked", "robot_linked",
+	"battery_voltage", "ds_robot_trip_time_ms", "missed_packet_count",
+}
+
+// TeamMatchLog is a CSV log of every DS packet received from a team during a match
+type TeamMatchLog struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewTeamMatchLog creates a log file for a team in dir named after the match and alliance station
+func NewTeamMatchLog(dir, match string, team int, allianceStation string) (*TeamMatchLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s_%s_%s_team%d.csv", time.Now().Format("20060102150405"), match, allianceStation, team)
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &TeamMatchLog{file: f, writer: csv.NewWriter(f)}
+	if err := l.write(teamMatchLogHeader); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *TeamMatchLog) write(record []string) error {
+	if err := l.writer.Write(record); err != nil {
+		return err
+	}
+	// Flush every record so the log is complete even if the FMS dies mid-match
+	l.writer.Flush()
+	return l.writer.Error()
+}
+
+// LogDsPacket adds a line to the log with the DS and robot state after receiving a packet
+func (l *TeamMatchLog) LogDsPacket(matchTime time.Duration, packetType int, dsConn *Conn) error {
+	return l.write([]string{
+		time.Now().Format(time.RFC3339Nano),
+		strconv.FormatFloat(matchTime.Seconds(), 'f', 3, 64),
+		strconv.Itoa(packetType),
+		strconv.Itoa(dsConn.TeamId),
+		dsConn.AllianceStation,
+		strconv.FormatBool(dsConn.Enabled),
+		strconv.FormatBool(dsConn.Auto),
+		strconv.FormatBool(dsConn.Estop),
+		strconv.FormatBool(dsConn.DsLinked),
+		strconv.FormatBool(dsConn.RadioLinked),
+		strconv.FormatBool(dsConn.RobotLinked),
+		strconv.FormatFloat(dsConn.BatteryVoltage, 'f', 2, 64),
+		strconv.Itoa(dsConn.DsRobotTripTimeMs),
+		strconv.Itoa(dsConn.MissedPacketCount),
+	})
+}
+
+// Close flushes and closes the log file
+func (l *TeamMatchLog) Close() error {
+	l.writer.Flush()
+	if err := l.writer.Error(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,50 @@
+package driverstation
+
+import (
+	"fmt"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultFmsIP is the FMS address hardcoded into the DS
+const DefaultFmsIP = "10.0.100.5"
+
+// fieldNetwork is the subnet the FMS and field access point live on
+var fieldNetwork = &net.IPNet{IP: net.IPv4(10, 0, 100, 0), Mask: net.CIDRMask(24, 32)}
+
+// detectFmsIP finds a local address on the field network, preferring the address the DS connects to.
+func detectFmsIP() (string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+
+	var fallbackIP, fallbackInterface string
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Debugf("Unable to get addresses of interface %s: %v", iface.Name, err)
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !fieldNetwork.Contains(ipNet.IP) {
+				continue
+			}
+			if ipNet.IP.Equal(net.ParseIP(DefaultFmsIP)) {
+				log.Infof("Found FMS address %s on interface %s", DefaultFmsIP, iface.Name)
+				return DefaultFmsIP, nil
+			}
+			if fallbackIP == "" {
+				fallbackIP, fallbackInterface = ipNet.IP.String(), iface.Name
+			}
+		}
+	}
+
+	if fallbackIP == "" {
+		return "", fmt.Errorf("no local interface has an address on the field network %s (add %s to an interface or set the FMS IP explicitly)", fieldNetwork, DefaultFmsIP)
+	}
+	log.Warnf("Using %s on interface %s as the FMS address, but driver stations expect %s", fallbackIP, fallbackInterface, DefaultFmsIP)
+	return fallbackIP, nil
+}
@@ -0,0 +1,50 @@
+package driverstation
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartNonLocalFmsIP(t *testing.T) {
+	// 192.0.2.0/24 is reserved for documentation, so it's never on a local interface
+	c := NewComms(Config{FmsIP: "192.0.2.5", TcpListenPort: 21751, UdpReceivePort: 21161}, nil)
+	err := c.Start()
+	if err == nil {
+		c.Stop()
+		t.Fatal("started on an address that isn't on this machine")
+	}
+	if !strings.Contains(err.Error(), "192.0.2.5") {
+		t.Errorf("error %q doesn't name the address", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := NewComms(Config{FmsIP: "127.0.0.1", TcpListenPort: 21752, UdpReceivePort: 21162}, nil)
+	if err := c.Reset(); err == nil {
+		t.Error("reset communication that was never started")
+	}
+
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	c.SetTeams(map[string]int{"R1": 254})
+	fms, ds := net.Pipe()
+	defer ds.Close()
+	c.allianceStations["R1"].DsConn = &Conn{TeamId: 254, AllianceStation: "R1", tcpConn: fms}
+
+	defer func(delay time.Duration) { resetDelay = delay }(resetDelay)
+	resetDelay = 0
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if c.allianceStations["R1"].DsConn != nil {
+		t.Error("DS connection is still attached after a reset")
+	}
+	if _, err := ds.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("DS connection is still open after a reset: %v", err)
+	}
+}
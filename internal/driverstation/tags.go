@@ -0,0 +1,216 @@
+package driverstation
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// Frame types sent from the DS to the FMS over TCP. Besides its own status, the DS forwards the robot's
+// diagnostic tags, which use the same IDs as in the robot to DS protocol.
+const (
+	tagRadioEvent   = 0x00 // Message string
+	tagDiskInfo     = 0x04 // Block (uint32), free space (uint32)
+	tagCpuInfo      = 0x05 // CPU count (uint8), then per CPU: critical, above normal, normal, low usage (float32 %)
+	tagRamInfo      = 0x06 // Block (uint32), free space (uint32)
+	tagPdpLog       = 0x08 // Unknown (uint8), 16 channel currents packed as 10 bit values in 1/8 A
+	tagVersionInfo  = 0x0a // Device type (uint8), unknown (uint16), ID (uint8), name (uint8 size + string), version (uint8 size + string)
+	tagErrorMessage = 0x0b // Timestamp (float32), sequence (uint16), unknown (uint16), code (int32), flags (uint8), details, location, call stack (each uint16 size + string)
+	tagStdout       = 0x0c // Timestamp (float32), sequence (uint16), message string
+	tagCanMetrics   = 0x0e // Utilization (float32 %), bus off (uint32), TX full (uint32), RX errors (uint8), TX errors (uint8)
+	tagRobotStatus  = 0x16 // Trip time (uint8 half ms), lost packets (uint8), voltage (uint16 volts * 256), status (uint8), CAN utilization (uint8 %)
+	tagTeamNumber   = 0x18 // Team number (uint16)
+	tagKeepAlive    = 0x1c // Empty
+)
+
+// pdpChannels is the number of PDP channels reported in a PDP log tag
+const pdpChannels = 16
+
+// maxLogMessages is the number of most recent robot messages kept per DS
+const maxLogMessages = 50
+
+var errShortPayload = errors.New("payload too short")
+
+// LogMessage is a message printed or an error raised by robot code, or an event from the radio
+type LogMessage struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"` // "event", "error", "warning" or "stdout"
+	Code    int32     `json:"code,omitempty"`
+	Message string    `json:"message"`
+}
+
+// payloadReader reads big-endian values from a frame payload without reading past its end
+type payloadReader struct {
+	data []byte
+	err  error
+}
+
+func (r *payloadReader) next(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = errShortPayload
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *payloadReader) u8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *payloadReader) u16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *payloadReader) u32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *payloadReader) f32() float32 {
+	return math.Float32frombits(r.u32())
+}
+
+func (r *payloadReader) str8() string {
+	return string(r.next(int(r.u8())))
+}
+
+func (r *payloadReader) str16() string {
+	return string(r.next(int(r.u16())))
+}
+
+func (r *payloadReader) rest() []byte {
+	return r.next(len(r.data))
+}
+
+// decodeTag updates the connection from a frame of the given type sent by the DS
+func (dsConn *Conn) decodeTag(frameType byte, payload []byte) error {
+	r := &payloadReader{data: payload}
+
+	switch frameType {
+	case tagKeepAlive:
+		// DS keepalive packet; do nothing.
+	case tagRobotStatus:
+		dsConn.decodeStatusPacket(r)
+	case tagCpuInfo:
+		count := int(r.u8())
+		var total float64
+		for i := 0; i < count; i++ {
+			total += float64(r.f32() + r.f32() + r.f32() + r.f32())
+		}
+		if r.err == nil && count > 0 {
+			dsConn.CpuUsage = total / float64(count)
+		}
+	case tagRamInfo:
+		r.u32()
+		if free := r.u32(); r.err == nil {
+			dsConn.RamFreeBytes = free
+		}
+	case tagDiskInfo:
+		r.u32()
+		if free := r.u32(); r.err == nil {
+			dsConn.DiskFreeBytes = free
+		}
+	case tagPdpLog:
+		r.u8()
+		packed := r.next((pdpChannels*10 + 7) / 8)
+		if r.err == nil {
+			for i := 0; i < pdpChannels; i++ {
+				dsConn.PdpCurrents[i] = float64(unpack10(packed, i)) / 8
+			}
+		}
+	case tagCanMetrics:
+		utilization, busOff, txFull, rxErrors, txErrors := r.f32(), r.u32(), r.u32(), r.u8(), r.u8()
+		if r.err == nil {
+			dsConn.CanUtilization = float64(utilization)
+			dsConn.CanBusOff = int(busOff)
+			dsConn.CanTxFull = int(txFull)
+			dsConn.CanRxErrors = int(rxErrors)
+			dsConn.CanTxErrors = int(txErrors)
+		}
+	case tagVersionInfo:
+		r.u8()
+		r.u16()
+		r.u8()
+		name, version := r.str8(), r.str8()
+		if r.err == nil {
+			if dsConn.Versions == nil {
+				dsConn.Versions = map[string]string{}
+			}
+			dsConn.Versions[name] = version
+		}
+	case tagRadioEvent:
+		if message := r.rest(); r.err == nil {
+			dsConn.addLogMessage(LogMessage{Type: "event", Message: string(message)})
+		}
+	case tagErrorMessage:
+		r.f32()
+		r.u16()
+		r.u16()
+		code := int32(r.u32())
+		flags := r.u8()
+		details, location, _ := r.str16(), r.str16(), r.str16()
+		if r.err == nil {
+			msg := LogMessage{Type: "warning", Code: code, Message: details}
+			if flags&0x01 != 0 {
+				msg.Type = "error"
+			}
+			if location != "" {
+				msg.Message += " (" + location + ")"
+			}
+			dsConn.addLogMessage(msg)
+		}
+	case tagStdout:
+		r.f32()
+		r.u16()
+		if message := r.rest(); r.err == nil {
+			dsConn.addLogMessage(LogMessage{Type: "stdout", Message: string(message)})
+		}
+	}
+
+	return r.err
+}
+
+// Deserializes a packet from the DS into a structure representing the DS/robot status.
+func (dsConn *Conn) decodeStatusPacket(r *payloadReader) {
+	tripTime, missedPackets := r.u8(), r.u8()
+	if r.err != nil {
+		return
+	}
+
+	// Average DS-robot trip time in milliseconds.
+	dsConn.DsRobotTripTimeMs = int(tripTime) / 2
+
+	// Number of missed packets sent from the DS to the robot.
+	dsConn.MissedPacketCount = int(missedPackets) - dsConn.missedPacketOffset
+
+	// Older DS versions stop after the packet counts.
+	r.u16() // Battery voltage, also reported over UDP
+	r.u8()  // Status
+	canUtilization := r.u8()
+	if r.err != nil {
+		r.err = nil
+		return
+	}
+	dsConn.CanUtilization = float64(canUtilization)
+}
+
+// unpack10 gets the i-th big-endian 10 bit value from a packed byte slice
+func unpack10(packed []byte, i int) uint16 {
+	bit := i * 10
+	word := uint32(packed[bit/8])<<16 | uint32(packed[bit/8+1])<<8
+	if bit/8+2 < len(packed) {
+		word |= uint32(packed[bit/8+2])
+	}
+	return uint16(word>>(24-10-bit%8)) & 0x3ff
+}
+
+// addLogMessage records a robot message, keeping only the most recent ones
+func (dsConn *Conn) addLogMessage(msg LogMessage) {
+	msg.Time = time.Now()
+	dsConn.LogMessages = append(dsConn.LogMessages, msg)
+	if len(dsConn.LogMessages) > maxLogMessages {
+		dsConn.LogMessages = dsConn.LogMessages[len(dsConn.LogMessages)-maxLogMessages:]
+	}
+}
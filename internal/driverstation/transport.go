@@ -0,0 +1,70 @@
+package driverstation
+
+import (
+	"fmt"
+	"net"
+)
+
+// Transport connects driver stations to Comms without the field network, for tests. It is only set through
+// Config.Transport, so driver stations on a running field can't be replaced. Driver stations from a transport are
+// driven by the transport rather than by Start.
+type Transport interface {
+	// Bind is called once when Comms is created, with the connector the transport attaches driver stations through
+	Bind(connector Connector)
+}
+
+// Connector attaches driver stations from a Transport to Comms
+type Connector interface {
+	// Connect attaches a driver station for the team assigned to a station, replacing any existing connection.
+	// Control packets are written to udpConn and TCP frames to tcpConn.
+	Connect(allianceStation string, tcpConn, udpConn net.Conn) error
+	// SetLinks sets the DS, radio and robot links reported by a station's driver station
+	SetLinks(allianceStation string, ds, radio, robot bool) error
+	// SendControlPackets sends a round of control packets to every driver station immediately
+	SendControlPackets()
+}
+
+// transportConnector is the Connector given to a Transport
+type transportConnector struct {
+	c *Comms
+}
+
+func (t transportConnector) Connect(allianceStation string, tcpConn, udpConn net.Conn) error {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	station := t.c.allianceStations[allianceStation]
+	if station == nil || station.Team == 0 {
+		return fmt.Errorf("no team in station %s", allianceStation)
+	}
+	if station.DsConn != nil {
+		station.DsConn.close()
+	}
+	station.DsConn = &Conn{
+		TeamId:          station.Team,
+		AllianceStation: allianceStation,
+		Estop:           station.Estop,
+		DsLinked:        true,
+		RadioLinked:     true,
+		RobotLinked:     true,
+		tcpConn:         tcpConn,
+		udpConn:         udpConn,
+	}
+	return nil
+}
+
+func (t transportConnector) SetLinks(allianceStation string, ds, radio, robot bool) error {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	station := t.c.allianceStations[allianceStation]
+	if station == nil || station.DsConn == nil {
+		return fmt.Errorf("no driver station in station %s", allianceStation)
+	}
+	station.DsConn.DsLinked, station.DsConn.RadioLinked, station.DsConn.RobotLinked = ds, radio, robot
+	return nil
+}
+
+func (t transportConnector) SendControlPackets() {
+	t.c.sendDsPacket()
+}
@@ -0,0 +1,64 @@
+package driverstation
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	udpStatusPacketMinBytes = 8
+	udpProtocolVersion      = 0
+	maxUdpPacketBytes       = 1500
+
+	// Sequence gaps larger than this are treated as the DS restarting rather than lost packets
+	maxUdpSequenceGap = 1000
+)
+
+// udpStatusPacket is the fixed header of the status packet a DS sends over UDP
+type udpStatusPacket struct {
+	Sequence       uint16
+	RadioLinked    bool
+	RobotLinked    bool
+	TeamId         int
+	BatteryVoltage float64
+}
+
+// decodeUdpStatusPacket parses a DS UDP status packet, rejecting truncated and foreign packets
+func decodeUdpStatusPacket(data []byte) (*udpStatusPacket, error) {
+	if len(data) < udpStatusPacketMinBytes {
+		return nil, fmt.Errorf("packet length %d is shorter than %d bytes", len(data), udpStatusPacketMinBytes)
+	}
+	if data[2] != udpProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d", data[2])
+	}
+
+	return &udpStatusPacket{
+		Sequence:    binary.BigEndian.Uint16(data[0:2]),
+		RadioLinked: data[3]&0x10 != 0,
+		RobotLinked: data[3]&0x20 != 0,
+		TeamId:      int(binary.BigEndian.Uint16(data[4:6])),
+		// Robot battery voltage, stored as volts * 256.
+		BatteryVoltage: float64(data[6]) + float64(data[7])/256,
+	}, nil
+}
+
+// trackUdpSequence counts packets lost between the last UDP status packet and this one
+func (dsConn *Conn) trackUdpSequence(sequence uint16) {
+	if dsConn.UdpPacketsReceived > 0 {
+		gap := int(sequence-dsConn.lastUdpSequence) - 1
+		if gap > 0 && gap < maxUdpSequenceGap {
+			dsConn.UdpPacketsLost += gap
+		}
+	}
+	dsConn.lastUdpSequence = sequence
+	dsConn.UdpPacketsReceived++
+}
+
+// UdpPacketLoss gets the percentage of UDP status packets from the DS that never arrived
+func (dsConn *Conn) UdpPacketLoss() float64 {
+	total := dsConn.UdpPacketsReceived + dsConn.UdpPacketsLost
+	if total == 0 {
+		return 0
+	}
+	return float64(dsConn.UdpPacketsLost) / float64(total) * 100
+}
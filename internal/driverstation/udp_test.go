@@ -0,0 +1,108 @@
+package driverstation
+
+import "testing"
+
+func TestDecodeUdpStatusPacket(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		want    udpStatusPacket
+		invalid bool
+	}{
+		{
+			name: "robot linked",
+			data: []byte{0x01, 0x02, 0x00, 0x30, 0x00, 0xfe, 0x0c, 0x80},
+			want: udpStatusPacket{Sequence: 0x0102, RadioLinked: true, RobotLinked: true, TeamId: 254, BatteryVoltage: 12.5},
+		},
+		{
+			name: "trailing tags",
+			data: []byte{0x00, 0x05, 0x00, 0x10, 0x0d, 0x05, 0x00, 0x00, 0x02, 0x07, 0x00},
+			want: udpStatusPacket{Sequence: 5, RadioLinked: true, TeamId: 3333},
+		},
+		{name: "truncated", data: []byte{0x00, 0x05, 0x00, 0x30, 0x00, 0xfe}, invalid: true},
+		{name: "empty", data: nil, invalid: true},
+		{name: "unknown protocol version", data: []byte{0x00, 0x05, 0x02, 0x30, 0x00, 0xfe, 0x0c, 0x80}, invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			packet, err := decodeUdpStatusPacket(tc.data)
+			if tc.invalid {
+				if err == nil {
+					t.Errorf("got %+v, want error", packet)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *packet != tc.want {
+				t.Errorf("got %+v, want %+v", *packet, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrackUdpSequence(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		sequences []uint16
+		lost      int
+	}{
+		{name: "in order", sequences: []uint16{1, 2, 3, 4}, lost: 0},
+		{name: "gaps", sequences: []uint16{1, 3, 4, 8}, lost: 4},
+		{name: "wraparound", sequences: []uint16{65534, 65535, 0, 2}, lost: 1},
+		{name: "duplicate", sequences: []uint16{1, 2, 2, 3}, lost: 0},
+		{name: "ds restart", sequences: []uint16{40000, 1, 2}, lost: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dsConn := &Conn{}
+			for _, sequence := range tc.sequences {
+				dsConn.trackUdpSequence(sequence)
+			}
+			if dsConn.UdpPacketsLost != tc.lost {
+				t.Errorf("got %d lost packets, want %d", dsConn.UdpPacketsLost, tc.lost)
+			}
+			if dsConn.UdpPacketsReceived != len(tc.sequences) {
+				t.Errorf("got %d received packets, want %d", dsConn.UdpPacketsReceived, len(tc.sequences))
+			}
+		})
+	}
+}
+
+func TestTeamFromIP(t *testing.T) {
+	for ip, team := range map[string]int{
+		"10.2.54.5":   254,
+		"10.99.99.20": 9999,
+		"10.0.1.5":    1,
+		"10.0.100.5":  0, // Field network
+		"192.168.1.5": 0,
+		"127.0.0.1":   0,
+		"not an ip":   0,
+	} {
+		if got := teamFromIP(ip); got != team {
+			t.Errorf("teamFromIP(%q) = %d, want %d", ip, got, team)
+		}
+	}
+}
+
+func TestWrongStation(t *testing.T) {
+	c := NewComms(Config{}, nil)
+	c.SetTeams(map[string]int{"R1": 254, "B2": 971})
+
+	for _, tc := range []struct {
+		ip          string
+		team        int
+		wrong       bool
+		pluggedInto string
+	}{
+		{"10.2.54.5", 254, false, ""},   // Own station
+		{"10.9.71.5", 254, true, "B2"},  // Another team's station
+		{"10.11.14.5", 254, true, ""},   // Station of a team that isn't on the field
+		{"10.0.100.20", 254, false, ""}, // Field network address
+		{"127.0.0.10", 971, false, ""},  // Not a team address
+	} {
+		wrong, pluggedInto := c.wrongStation(tc.ip, tc.team)
+		if wrong != tc.wrong || pluggedInto != tc.pluggedInto {
+			t.Errorf("wrongStation(%q, %d) = %v, %q, want %v, %q", tc.ip, tc.team, wrong, pluggedInto, tc.wrong, tc.pluggedInto)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package dssim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/natesales/bunnyfms/internal/driverstation"
+)
+
+const (
+	keepAliveInterval = 500 * time.Millisecond
+	reconnectInterval = 2 * time.Second
+)
+
+// Config is the configuration of a simulated driver station
+type Config struct {
+	Team    int
+	LocalIP string // Address to connect from and receive control packets on, unique per DS
+	FmsIP   string
+
+	TcpPort        int // FMS TCP port
+	UdpPort        int // FMS UDP status port
+	ControlPort    int // Local UDP port to receive control packets on
+	StatusInterval time.Duration
+
+	BatteryVoltage float64
+	RobotLinked    bool
+	FlapInterval   time.Duration // Time between robot link toggles, or 0 to keep the link steady
+}
+
+// withDefaults fills in unset ports and intervals with the values a real DS uses
+func (cfg Config) withDefaults() Config {
+	if cfg.TcpPort == 0 {
+		cfg.TcpPort = driverstation.DefaultTcpListenPort
+	}
+	if cfg.UdpPort == 0 {
+		cfg.UdpPort = driverstation.DefaultUdpReceivePort
+	}
+	if cfg.ControlPort == 0 {
+		cfg.ControlPort = driverstation.DefaultUdpSendPort
+	}
+	if cfg.StatusInterval <= 0 {
+		cfg.StatusInterval = 250 * time.Millisecond
+	}
+	return cfg
+}
+
+// DriverStation is a simulated driver station
+type DriverStation struct {
+	cfg  Config
+	quit chan bool
+	wg   sync.WaitGroup
+
+	mu             sync.Mutex
+	robotLinked    bool
+	station        byte
+	wrongStation   bool
+	connected      bool
+	gameData       string
+	controlPackets int
+	lastControl    driverstation.ControlPacket
+	controlConn    *net.UDPConn
+	tcpConn        net.Conn
+}
+
+// New creates a simulated driver station
+func New(cfg Config) *DriverStation {
+	cfg = cfg.withDefaults()
+	return &DriverStation{cfg: cfg, robotLinked: cfg.RobotLinked}
+}
+
+// Start starts listening for control packets and connecting to the FMS
+func (d *DriverStation) Start() error {
+	controlAddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(d.cfg.LocalIP, strconv.Itoa(d.cfg.ControlPort)))
+	if err != nil {
+		return err
+	}
+	controlConn, err := net.ListenUDP("udp4", controlAddr)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.quit = make(chan bool)
+	d.controlConn = controlConn
+	d.mu.Unlock()
+
+	d.wg.Add(3)
+	go d.receiveControlPackets(controlConn)
+	go d.sendStatusPackets()
+	go d.connectTcp()
+	return nil
+}
+
+// Close stops the simulated driver station. Closing it again does nothing.
+func (d *DriverStation) Close() {
+	d.mu.Lock()
+	if d.quit == nil || d.stopped() {
+		d.mu.Unlock()
+		return
+	}
+	close(d.quit)
+	d.controlConn.Close()
+	if d.tcpConn != nil {
+		d.tcpConn.Close()
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// SetRobotLinked sets whether the simulated robot is connected to the DS
+func (d *DriverStation) SetRobotLinked(linked bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.robotLinked = linked
+}
+
+// LastControlPacket gets the most recent control packet and the number received so far
+func (d *DriverStation) LastControlPacket() (driverstation.ControlPacket, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastControl, d.controlPackets
+}
+
+// Assignment gets the station assigned by the FMS and whether the DS was told it is in the wrong station
+func (d *DriverStation) Assignment() (station byte, wrongStation, connected bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.station, d.wrongStation, d.connected
+}
+
+// GameData gets the last game-specific message sent by the FMS
+func (d *DriverStation) GameData() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.gameData
+}
+
+func (d *DriverStation) stopped() bool {
+	select {
+	case <-d.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// receiveControlPackets records control packets sent by the FMS
+func (d *DriverStation) receiveControlPackets(conn *net.UDPConn) {
+	defer d.wg.Done()
+
+	data := make([]byte, 1500)
+	for {
+		n, err := conn.Read(data)
+		if err != nil {
+			if !d.stopped() {
+				log.Warnf("Team %d: error reading control packet: %v", d.cfg.Team, err)
+			}
+			return
+		}
+		packet, err := driverstation.DecodeControlPacket(data[:n])
+		if err != nil {
+			log.Warnf("Team %d: %v", d.cfg.Team, err)
+			continue
+		}
+		d.mu.Lock()
+		d.lastControl = packet
+		d.controlPackets++
+		d.mu.Unlock()
+	}
+}
+
+// sendStatusPackets sends UDP status packets to the FMS, flapping the robot link if configured
+func (d *DriverStation) sendStatusPackets() {
+	defer d.wg.Done()
+
+	conn, err := net.DialUDP("udp4",
+		&net.UDPAddr{IP: net.ParseIP(d.cfg.LocalIP)},
+		&net.UDPAddr{IP: net.ParseIP(d.cfg.FmsIP), Port: d.cfg.UdpPort})
+	if err != nil {
+		log.Warnf("Team %d: unable to open status socket: %v", d.cfg.Team, err)
+		return
+	}
+	defer conn.Close()
+
+	ticker := time.NewTicker(d.cfg.StatusInterval)
+	defer ticker.Stop()
+	lastFlap := time.Now()
+
+	var sequence uint16
+	for {
+		select {
+		case <-d.quit:
+			return
+		case <-ticker.C:
+		}
+
+		d.mu.Lock()
+		if d.cfg.FlapInterval > 0 && time.Since(lastFlap) >= d.cfg.FlapInterval {
+			d.robotLinked = !d.robotLinked
+			lastFlap = time.Now()
+			log.Infof("Team %d: robot link %v", d.cfg.Team, d.robotLinked)
+		}
+		packet := d.statusPacket(sequence)
+		d.mu.Unlock()
+
+		if _, err := conn.Write(packet); err != nil {
+			log.Debugf("Team %d: unable to send status packet: %v", d.cfg.Team, err)
+		}
+		sequence++
+	}
+}
+
+// statusPacket builds a UDP status packet. Callers must hold d.mu.
+func (d *DriverStation) statusPacket(sequence uint16) []byte {
+	packet := make([]byte, 8)
+	binary.BigEndian.PutUint16(packet[0:2], sequence)
+	packet[2] = 0    // Protocol version
+	packet[3] = 0x10 // Radio linked
+	if d.robotLinked {
+		packet[3] |= 0x20
+		packet[6] = byte(d.cfg.BatteryVoltage)
+		packet[7] = byte((d.cfg.BatteryVoltage - float64(packet[6])) * 256)
+	}
+	binary.BigEndian.PutUint16(packet[4:6], uint16(d.cfg.Team))
+	return packet
+}
+
+// connectTcp keeps a TCP connection to the FMS open, reconnecting whenever it drops
+func (d *DriverStation) connectTcp() {
+	defer d.wg.Done()
+
+	for !d.stopped() {
+		if err := d.runTcp(); err != nil && !d.stopped() {
+			log.Infof("Team %d: FMS connection lost: %v", d.cfg.Team, err)
+		}
+		d.mu.Lock()
+		d.connected = false
+		d.tcpConn = nil
+		d.mu.Unlock()
+
+		select {
+		case <-d.quit:
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+// runTcp performs the TCP handshake and then exchanges packets until the connection drops
+func (d *DriverStation) runTcp() error {
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(d.cfg.LocalIP)}, Timeout: time.Second}
+	conn, err := dialer.Dial("tcp4", net.JoinHostPort(d.cfg.FmsIP, strconv.Itoa(d.cfg.TcpPort)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	d.mu.Lock()
+	if d.stopped() {
+		d.mu.Unlock()
+		return nil
+	}
+	d.tcpConn = conn
+	d.mu.Unlock()
+
+	// Send the team number and wait for the station assignment.
+	if err := driverstation.WriteFrame(conn, 0x18, []byte{byte(d.cfg.Team >> 8), byte(d.cfg.Team)}); err != nil {
+		return err
+	}
+	frames := driverstation.NewFrameReader(conn)
+	frameType, payload, err := frames.Next()
+	if err != nil {
+		return err
+	}
+	if frameType != driverstation.FrameStationInfo || len(payload) < 2 {
+		return fmt.Errorf("unexpected assignment packet type %d", frameType)
+	}
+	d.mu.Lock()
+	d.station, d.wrongStation, d.connected = payload[0], payload[1] != 0, true
+	d.mu.Unlock()
+	log.Infof("Team %d: assigned station %d (wrong station: %v)", d.cfg.Team, payload[0], payload[1] != 0)
+
+	errs := make(chan error, 2)
+	go func() {
+		for {
+			frameType, payload, err := frames.Next()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if frameType == driverstation.FrameGameData && len(payload) >= 1 && int(payload[0]) <= len(payload)-1 {
+				d.mu.Lock()
+				d.gameData = string(payload[1 : 1+int(payload[0])])
+				d.mu.Unlock()
+				log.Infof("Team %d: game data %q", d.cfg.Team, d.gameData)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.quit:
+			return nil
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+			// Robot status: 10 ms trip time, no lost packets
+			if err := driverstation.WriteFrame(conn, 0x16, []byte{20, 0}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package dssim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/natesales/bunnyfms/internal/driverstation"
+)
+
+// Ports away from the defaults so the test doesn't collide with a running FMS
+const (
+	testTcpPort     = 21750
+	testUdpPort     = 21160
+	testControlPort = 21121
+)
+
+// waitFor polls cond until it passes or the timeout expires
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDriverStationComms(t *testing.T) {
+	comms := driverstation.NewComms(driverstation.Config{
+		FmsIP:                 "127.0.0.1",
+		ControlPacketInterval: 20 * time.Millisecond,
+		TcpListenPort:         testTcpPort,
+		UdpReceivePort:        testUdpPort,
+		UdpSendPort:           testControlPort,
+	}, func() driverstation.MatchStatus {
+		return driverstation.MatchStatus{Type: driverstation.MatchTypeQualification, Number: 12, Replay: 1, SecondsRemaining: 15}
+	})
+	comms.SetTeams(map[string]int{"R2": 254})
+	if err := comms.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer comms.Stop()
+
+	dsConfig := Config{
+		Team:           254,
+		LocalIP:        "127.0.0.10",
+		FmsIP:          "127.0.0.1",
+		TcpPort:        testTcpPort,
+		UdpPort:        testUdpPort,
+		ControlPort:    testControlPort,
+		StatusInterval: 20 * time.Millisecond,
+		BatteryVoltage: 12.5,
+		RobotLinked:    true,
+	}
+	ds := New(dsConfig)
+	if err := ds.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+
+	waitFor(t, "station assignment", func() bool {
+		_, _, connected := ds.Assignment()
+		return connected
+	})
+	if station, wrongStation, _ := ds.Assignment(); station != 1 || wrongStation {
+		t.Errorf("got station %d wrong station %v, want station 1 (R2)", station, wrongStation)
+	}
+
+	waitFor(t, "DS and robot link", func() bool {
+		stats := comms.ConnectionStats()["R2"]
+		return stats != nil && stats.DSLink && stats.RobotLink
+	})
+	if voltage := comms.ConnectionStats()["R2"].BatteryVoltage; voltage != 12.5 {
+		t.Errorf("got battery voltage %v, want 12.5", voltage)
+	}
+
+	waitFor(t, "control packet", func() bool {
+		_, count := ds.LastControlPacket()
+		return count > 0
+	})
+	packet, _ := ds.LastControlPacket()
+	if packet.Station != 1 || packet.MatchType != byte(driverstation.MatchTypeQualification) || packet.MatchNumber != 12 || packet.SecondsRemaining != 15 {
+		t.Errorf("unexpected control packet %s", packet)
+	}
+	if packet.Enabled || packet.Estop {
+		t.Errorf("robot enabled before the match: %s", packet)
+	}
+
+	comms.StartAuto()
+	waitFor(t, "auto enabled", func() bool {
+		packet, _ := ds.LastControlPacket()
+		return packet.Auto && packet.Enabled
+	})
+
+	comms.Estop("R2")
+	waitFor(t, "estop", func() bool {
+		packet, _ := ds.LastControlPacket()
+		return packet.Estop
+	})
+
+	comms.SendGameData("red", "LRL")
+	waitFor(t, "game data", func() bool {
+		return ds.GameData() == "LRL"
+	})
+
+	ds.SetRobotLinked(false)
+	waitFor(t, "robot link lost", func() bool {
+		return !comms.ConnectionStats()["R2"].RobotLink
+	})
+
+	// A DS that reconnects after the game data went out is sent it again
+	ds.Close()
+	reconnected := New(dsConfig)
+	if err := reconnected.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer reconnected.Close()
+	waitFor(t, "game data after reconnecting", func() bool {
+		return reconnected.GameData() == "LRL"
+	})
+}
@@ -1,47 +1,118 @@
 package field
 
 import (
+	"fmt"
 	"io"
+	"math"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hajimehoshi/go-mp3"
 	"github.com/hajimehoshi/oto"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/natesales/bunnyfms/internal/clock"
 	"github.com/natesales/bunnyfms/internal/driverstation"
 )
 
-var (
-	autoDuration    time.Duration
-	teleopDuration  time.Duration
-	endgameDuration time.Duration
+const (
+	stateIdle       = "Idle"
+	statePreMatch   = "PreMatch"
+	stateStartMatch = "StartMatch"
+	stateAuto       = "Auto"
+	statePause      = "Pause"
+	stateTeleop     = "Teleop"
+	statePostMatch  = "PostMatch"
+	stateFieldTest  = "FieldTest" // Robots are enabled one station at a time for checkout
+)
 
-	autoTimer    *time.Timer
-	teleopTimer  *time.Timer
-	endgameTimer *time.Timer
+// alliances are the alliances that can receive game-specific data
+var alliances = []string{"red", "blue"}
+
+// maxTeamNumber is the highest team number that fits in a 10.TE.AM.x address
+const maxTeamNumber = 25599
+
+// updatePeriod is how often a running match is evaluated for state transitions
+const updatePeriod = 10 * time.Millisecond
+
+// transitions maps each match state to the states it may move to
+var transitions = map[string][]string{
+	stateIdle:       {statePreMatch, stateStartMatch, stateFieldTest},
+	statePreMatch:   {stateStartMatch, stateIdle, stateFieldTest},
+	stateStartMatch: {stateAuto, stateIdle},
+	stateAuto:       {statePause, stateIdle},
+	statePause:      {stateTeleop, stateIdle},
+	stateTeleop:     {statePostMatch, stateIdle},
+	statePostMatch:  {stateIdle, statePreMatch, stateStartMatch, stateFieldTest},
+	stateFieldTest:  {stateIdle},
+}
 
-	autoStartedAt    time.Time
-	teleopStartedAt  time.Time
-	endgameStartedAt time.Time
-)
+// ArgumentError is returned when a command's arguments are invalid, rather than the field being in the wrong state
+type ArgumentError struct {
+	Err error
+}
 
-var (
-	gameSounds            bool
-	matchState, matchName string
-)
+func (e *ArgumentError) Error() string { return e.Err.Error() }
+func (e *ArgumentError) Unwrap() error { return e.Err }
 
-const (
-	stateIdle    = "Idle"
-	stateAuto    = "Auto"
-	stateTeleop  = "Teleop"
-	stateEndGame = "Endgame"
-)
+// argumentErrorf formats an ArgumentError
+func argumentErrorf(format string, args ...interface{}) error {
+	return &ArgumentError{fmt.Errorf(format, args...)}
+}
+
+// validateStation checks that an alliance station position exists
+func validateStation(position string) error {
+	if err := driverstation.ValidateStation(position); err != nil {
+		return &ArgumentError{err}
+	}
+	return nil
+}
+
+// Config is the event-wide field configuration
+type Config struct {
+	AutoDuration    time.Duration
+	PauseDuration   time.Duration
+	TeleopDuration  time.Duration
+	EndgameDuration time.Duration
+	GameDataDelay   time.Duration // Time into teleop to send game-specific data
+	Sounds          bool
+	DriverStation   driverstation.Config
+	Clock           clock.Clock // Clock for match timers, or nil for the system clock
+	Speed           float64     // How many times faster than real time matches run, for demos, or 0 for real time
+}
+
+// Arena owns the match state and the driver stations on the field
+type Arena struct {
+	mu sync.Mutex
+	ds *driverstation.Comms
+
+	cfg   Config
+	clock *clock.Scaled // Match clock, paused for field faults and sped up for demos
+
+	match          Match
+	matchState     string
+	matchStartedAt time.Time
+	abort          chan bool
+	endgame        bool      // Endgame warning has been given this match
+	paused         bool      // Match is paused with robots disabled and the match clock stopped
+	timeoutEndsAt  time.Time // End of the field timeout between matches, or zero if there isn't one
+
+	gameData     map[string]string // Alliance to game-specific message
+	gameDataSent bool
+
+	estops []*EstopRecord // E-stops in the current match, and earlier ones that haven't been cleared
+	record *MatchRecord   // Record of the current or last match
+
+	changes chan struct{} // Signalled when the match state changes
+}
 
 // playSound plays a game sound file
-func playSound(file string) {
-	if !gameSounds {
+func (a *Arena) playSound(file string) {
+	if !a.cfg.Sounds {
 		log.Warnf("Game sounds disabled, not playing %s", file)
 		return
 	}
@@ -72,29 +143,43 @@ func playSound(file string) {
 	}
 }
 
-// Setup creates a new field setup (once per event)
-func Setup(auto, teleop, endGame string, sounds bool) error {
-	// Parse durations
-	var err error
-	autoDuration, err = time.ParseDuration(auto)
-	if err != nil {
-		return err
+// NewArena creates a new field setup (once per event)
+func NewArena(cfg Config) (*Arena, error) {
+	if cfg.EndgameDuration > cfg.TeleopDuration {
+		return nil, fmt.Errorf("endgame duration %s is longer than teleop duration %s", cfg.EndgameDuration, cfg.TeleopDuration)
 	}
-	teleopDuration, err = time.ParseDuration(teleop)
-	if err != nil {
-		return err
+	if cfg.GameDataDelay < 0 || cfg.GameDataDelay > cfg.TeleopDuration {
+		return nil, fmt.Errorf("game data delay %s is outside teleop", cfg.GameDataDelay)
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = clock.System
 	}
-	endgameDuration, err = time.ParseDuration(endGame)
+	if cfg.Speed == 0 {
+		cfg.Speed = 1
+	}
+	matchClock, err := clock.NewScaled(cfg.Clock, cfg.Speed)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	matchState = stateIdle
-	gameSounds = sounds
+	a := &Arena{
+		cfg:        cfg,
+		clock:      matchClock,
+		match:      Match{Type: driverstation.MatchTypePractice, Replay: 1},
+		matchState: stateIdle,
+		gameData:   map[string]string{},
+		changes:    make(chan struct{}, 1),
+	}
+	a.ds = driverstation.NewComms(cfg.DriverStation, a.driverStationStatus)
 
-	log.Infof("Configuring FMS with auto: %s, teleop: %s, endgame: %s, sounds: %v", autoDuration, teleopDuration, endgameDuration, sounds)
+	log.Infof("Configuring FMS with auto: %s, pause: %s, teleop: %s, endgame: %s, game data delay: %s, sounds: %v",
+		cfg.AutoDuration, cfg.PauseDuration, cfg.TeleopDuration, cfg.EndgameDuration, cfg.GameDataDelay, cfg.Sounds)
+	if cfg.Speed != 1 {
+		log.Warnf("Demo mode: matches run at %gx speed", cfg.Speed)
+	}
 
-	return nil
+	return a, nil
 }
 
 func formatDuration(d time.Duration) string {
@@ -104,125 +189,735 @@ func formatDuration(d time.Duration) string {
 	return time.Unix(0, 0).UTC().Add(d.Round(time.Second)).Format("4:05")
 }
 
+// remaining gets how much of a period of length d is left when elapsed time has passed since it started
+func remaining(d, elapsed time.Duration) time.Duration {
+	if elapsed < 0 {
+		return d
+	}
+	if elapsed > d {
+		return 0
+	}
+	return d - elapsed
+}
+
+// running checks if a match is in progress
+func running(state string) bool {
+	switch state {
+	case stateStartMatch, stateAuto, statePause, stateTeleop:
+		return true
+	}
+	return false
+}
+
+// teleopStart gets the offset of the start of teleop from the start of the match
+func (a *Arena) teleopStart() time.Duration {
+	return a.cfg.AutoDuration + a.cfg.PauseDuration
+}
+
+// endgameStart gets the offset of the start of endgame from the start of the match
+func (a *Arena) endgameStart() time.Duration {
+	return a.teleopStart() + a.cfg.TeleopDuration - a.cfg.EndgameDuration
+}
+
+// matchEnd gets the offset of the end of the match from the start of the match
+func (a *Arena) matchEnd() time.Duration {
+	return a.teleopStart() + a.cfg.TeleopDuration
+}
+
+// matchTime gets the time elapsed since the start of the current match
+func (a *Arena) matchTime() time.Duration {
+	if !running(a.matchState) {
+		return 0
+	}
+	return a.clock.Now().Sub(a.matchStartedAt)
+}
+
+// driverStationStatus gets the match status to send to driver stations
+func (a *Arena) driverStationStatus() driverstation.MatchStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var left time.Duration
+	matchTime := a.matchTime()
+	switch a.matchState {
+	case stateIdle, statePreMatch:
+		left = a.cfg.AutoDuration
+	case stateStartMatch, stateAuto:
+		left = remaining(a.cfg.AutoDuration, matchTime)
+	case statePause:
+		left = a.cfg.TeleopDuration
+	case stateTeleop:
+		left = remaining(a.cfg.TeleopDuration, matchTime-a.teleopStart())
+	}
+
+	return driverstation.MatchStatus{
+		Type:             a.match.Type,
+		Number:           a.match.Number,
+		Replay:           a.match.Replay,
+		SecondsRemaining: int(math.Ceil(left.Seconds())),
+		MatchTime:        matchTime,
+	}
+}
+
 // State gets the game state
-func State() map[string]interface{} {
-	now := time.Now()
+func (a *Arena) State() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
+	readiness := a.ds.Readiness()
 	o := map[string]interface{}{
-		"name":      matchName,
-		"state":     matchState,
-		"alliances": TeamNumbers(),
-		"ds":        driverstation.ConnectionStats(),
+		"name":      a.match.Name(),
+		"match":     a.match,
+		"state":     a.matchState,
+		"running":   running(a.matchState),
+		"alliances": a.ds.TeamNumbers(),
+		"ds":        a.ds.ConnectionStats(),
+		"bypass":    a.ds.Bypassed(),
+		"readiness": readiness,
+		"estops":    a.estopState(),
+		"ready":     ready(readiness),
+		"game_data": a.gameDataState(),
+		"speed":     a.clock.Speed(),
+		"paused":    a.paused,
+		"endgame":   running(a.matchState) && a.endgame,
+		"timeout":   false,
 	}
 
-	if matchState == "Idle" {
-		o["auto_timer"] = formatDuration(autoDuration)
-		o["teleop_timer"] = formatDuration(teleopDuration)
-		o["endgame_timer"] = formatDuration(endgameDuration)
+	if !running(a.matchState) {
+		o["auto_timer"] = formatDuration(a.cfg.AutoDuration)
+		o["teleop_timer"] = formatDuration(a.cfg.TeleopDuration)
+		o["endgame_timer"] = formatDuration(a.cfg.EndgameDuration)
 		o["current_timer"] = "0:00"
+		if left := a.timeoutRemaining(); left > 0 {
+			o["timeout"] = true
+			o["current_timer"] = formatDuration(left)
+		}
 	} else {
-		o["auto_timer"] = formatDuration(autoDuration - now.Sub(autoStartedAt))
-		o["teleop_timer"] = formatDuration(teleopDuration - now.Sub(teleopStartedAt))
-		o["endgame_timer"] = formatDuration(endgameDuration - now.Sub(endgameStartedAt))
+		matchTime := a.matchTime()
+		o["auto_timer"] = formatDuration(remaining(a.cfg.AutoDuration, matchTime))
+		o["teleop_timer"] = formatDuration(remaining(a.cfg.TeleopDuration, matchTime-a.teleopStart()))
+		o["endgame_timer"] = formatDuration(remaining(a.cfg.EndgameDuration, matchTime-a.endgameStart()))
+
+		switch a.matchState {
+		case stateStartMatch, stateAuto:
+			o["current_timer"] = o["auto_timer"]
+		case statePause:
+			o["current_timer"] = formatDuration(remaining(a.cfg.PauseDuration, matchTime-a.cfg.AutoDuration))
+		default:
+			o["current_timer"] = o["teleop_timer"]
+		}
+	}
 
-		if matchState == "Auto" {
-			o["current_timer"] = formatDuration(autoDuration - now.Sub(autoStartedAt))
-		} else {
-			o["current_timer"] = formatDuration(teleopDuration - now.Sub(teleopStartedAt))
+	return o
+}
+
+// Running reports whether a match is in progress
+func (a *Arena) Running() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return running(a.matchState)
+}
+
+// Changes is signalled whenever the match moves to a new state. Signals are coalesced, so a receiver that falls behind
+// sees one signal for several changes.
+func (a *Arena) Changes() <-chan struct{} {
+	return a.changes
+}
+
+// notify signals a state change without blocking
+func (a *Arena) notify() {
+	select {
+	case a.changes <- struct{}{}:
+	default:
+	}
+}
+
+// transition moves the match into a new state if the state machine allows it
+func (a *Arena) transition(to string) error {
+	for _, allowed := range transitions[a.matchState] {
+		if allowed == to {
+			log.Infof("Match %s: %s -> %s", a.match, a.matchState, to)
+			a.matchState = to
+			a.notify()
+			return nil
 		}
 	}
+	return fmt.Errorf("invalid match state transition from %s to %s", a.matchState, to)
+}
 
+// update advances a running match to the next state once the current period has elapsed
+func (a *Arena) update() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.paused {
+		return
+	}
+
+	var err error
+	matchTime := a.matchTime()
+	switch a.matchState {
+	case stateStartMatch:
+		if err = a.transition(stateAuto); err == nil {
+			go a.playSound("auto.mp3")
+			a.ds.StartAuto()
+		}
+	case stateAuto:
+		if matchTime >= a.cfg.AutoDuration {
+			if err = a.transition(statePause); err == nil {
+				a.ds.StopMatch()
+			}
+		}
+	case statePause:
+		if matchTime >= a.teleopStart() {
+			if err = a.transition(stateTeleop); err == nil {
+				go a.playSound("teleop.mp3")
+				a.ds.StartTeleop()
+			}
+		}
+	case stateTeleop:
+		a.sendGameDataIfDue(matchTime)
+		// Endgame is only a warning, robots play on until teleop ends
+		if !a.endgame && a.cfg.EndgameDuration > 0 && matchTime >= a.endgameStart() && matchTime < a.matchEnd() {
+			log.Infof("Match %s: endgame", a.match)
+			a.endgame = true
+			go a.playSound("endgame.mp3")
+		}
+		if matchTime >= a.matchEnd() {
+			if err = a.transition(statePostMatch); err == nil {
+				go a.playSound("end.mp3")
+				a.ds.StopMatch()
+				a.ds.CloseLogs()
+				a.endRecord(false)
+			}
+		}
+	}
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
+// sendGameDataIfDue sends game-specific data to driver stations once the configured point in teleop is reached
+func (a *Arena) sendGameDataIfDue(matchTime time.Duration) {
+	if a.gameDataSent || matchTime < a.teleopStart()+a.cfg.GameDataDelay {
+		return
+	}
+	log.Infof("Match %s: sending game data %+v", a.match, a.gameData)
+	for _, alliance := range alliances {
+		a.ds.SendGameData(alliance, a.gameData[alliance])
+	}
+	a.gameDataSent = true
+}
+
+// gameDataState gets the game-specific data for each alliance and whether it has been sent this match
+func (a *Arena) gameDataState() map[string]interface{} {
+	o := map[string]interface{}{"sent": a.gameDataSent}
+	for _, alliance := range alliances {
+		o[alliance] = a.gameData[alliance]
+	}
 	return o
 }
 
-// Start starts a match
-func Start() {
-	go func() {
-		log.Infof("Match %s: starting auto", matchName)
-		go playSound("auto.mp3")
-		driverstation.StartAuto()
-		matchState = stateAuto
-		autoStartedAt = time.Now()
-		autoTimer = time.NewTimer(autoDuration)
-		<-autoTimer.C
-
-		log.Infof("Match %s: starting teleop", matchName)
-		go playSound("teleop.mp3")
-		driverstation.StartTeleop()
-		matchState = stateTeleop
-		teleopStartedAt = time.Now()
-		teleopTimer = time.NewTimer(teleopDuration - endgameDuration)
-		<-teleopTimer.C
-
-		log.Infof("Match %s: starting endgame", matchName)
-		go playSound("endgame.mp3")
-		matchState = stateEndGame
-		driverstation.StopMatch()
-		endgameStartedAt = time.Now()
-		endgameTimer = time.NewTimer(endgameDuration)
-		<-endgameTimer.C
-
-		log.Infof("Match %s: finished", matchName)
-		go playSound("end.mp3")
-		matchState = stateIdle
-	}()
+// SetGameData sets the game-specific data for an alliance ("red" or "blue")
+func (a *Arena) SetGameData(alliance, gameData string) error {
+	alliance = strings.ToLower(alliance)
+	if alliance != "red" && alliance != "blue" {
+		return argumentErrorf("unknown alliance %q", alliance)
+	}
+	if len(gameData) > driverstation.MaxGameDataLength {
+		return argumentErrorf("game data is longer than %d bytes", driverstation.MaxGameDataLength)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	log.Infof("Updating %s game data to %q", alliance, gameData)
+	a.gameData[alliance] = gameData
+
+	// Robots already received this match's game data, so send the correction straight away
+	if running(a.matchState) && a.gameDataSent {
+		a.ds.SendGameData(alliance, gameData)
+	}
+	return nil
 }
 
-// Stop stops a match
-func Stop() {
-	log.Infof("Match %s: aborting", matchName)
-	go playSound("abort.mp3")
-	go driverstation.StopMatch()
-	matchState = "Idle"
-	for _, timer := range []*time.Timer{autoTimer, teleopTimer, endgameTimer} {
-		if timer != nil {
-			timer.Stop()
+// run updates the match state until the match ends or is aborted
+func (a *Arena) run(abort chan bool) {
+	ticker := time.NewTicker(updatePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-abort:
+			return
+		case <-ticker.C:
+			a.update()
+			a.mu.Lock()
+			done := !running(a.matchState)
+			a.mu.Unlock()
+			if done {
+				return
+			}
 		}
 	}
 }
 
+// ready checks if every alliance station is ready for a match to start
+func ready(readiness map[string][]string) bool {
+	for _, reasons := range readiness {
+		if len(reasons) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// notReady describes the alliance stations that aren't ready for a match to start
+func notReady(readiness map[string][]string) string {
+	var stations []string
+	for position, reasons := range readiness {
+		if len(reasons) > 0 {
+			stations = append(stations, fmt.Sprintf("%s: %s", position, strings.Join(reasons, ", ")))
+		}
+	}
+	sort.Strings(stations)
+	return strings.Join(stations, "; ")
+}
+
+// Start starts a match once every alliance station is ready, or regardless if force is set
+func (a *Arena) Start(force bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if running(a.matchState) {
+		return fmt.Errorf("match %s is already running", a.match)
+	}
+	if readiness := a.ds.Readiness(); !ready(readiness) {
+		if !force {
+			return fmt.Errorf("match %s isn't ready to start (%s)", a.match, notReady(readiness))
+		}
+		log.Warnf("Match %s: force starting with %s", a.match, notReady(readiness))
+	}
+	if err := a.transition(stateStartMatch); err != nil {
+		return err
+	}
+
+	if a.timeoutRemaining() > 0 {
+		log.Infof("Match %s: starting before the field timeout ended", a.match)
+	}
+	a.timeoutEndsAt = time.Time{}
+
+	a.endgame = false
+
+	// Clear any game data left on the driver stations from the last match
+	a.gameDataSent = false
+	for _, alliance := range alliances {
+		a.ds.SendGameData(alliance, "")
+	}
+
+	a.ds.OpenLogs(a.match.logName())
+	a.matchStartedAt = a.clock.Now()
+	a.startRecord()
+	a.abort = make(chan bool)
+	go a.run(a.abort)
+	return nil
+}
+
+// Stop stops a match
+func (a *Arena) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !running(a.matchState) {
+		return fmt.Errorf("no match is running")
+	}
+
+	log.Infof("Match %s: aborting", a.match)
+	if a.paused {
+		a.paused = false
+		a.clock.Resume()
+	}
+	go a.playSound("abort.mp3")
+	a.ds.StopMatch()
+	a.ds.CloseLogs()
+	a.endRecord(true)
+	if a.abort != nil {
+		close(a.abort)
+		a.abort = nil
+	}
+	return a.transition(stateIdle)
+}
+
+// Pause disables all robots and freezes the match timers until the match is resumed
+func (a *Arena) Pause() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !running(a.matchState) {
+		return fmt.Errorf("no match is running")
+	}
+	if a.paused {
+		return fmt.Errorf("match %s is already paused", a.match)
+	}
+
+	log.Infof("Match %s: pausing at %s", a.match, a.matchTime().Round(time.Millisecond))
+	a.clock.Pause()
+	a.paused = true
+	a.ds.DisableAll()
+	return nil
+}
+
+// Resume restarts a paused match from the point it was paused at
+func (a *Arena) Resume() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.paused {
+		return fmt.Errorf("no match is paused")
+	}
+
+	log.Infof("Match %s: resuming at %s", a.match, a.matchTime().Round(time.Millisecond))
+	a.paused = false
+	a.clock.Resume()
+	switch a.matchState {
+	case stateAuto:
+		a.ds.StartAuto()
+	case stateTeleop:
+		a.ds.StartTeleop()
+	}
+	return nil
+}
+
+// timeoutRemaining gets the time left in the field timeout, or 0 if there isn't one
+func (a *Arena) timeoutRemaining() time.Duration {
+	if a.timeoutEndsAt.IsZero() {
+		return 0
+	}
+	return remaining(a.timeoutEndsAt.Sub(a.clock.Now()), 0)
+}
+
+// StartTimeout starts a field timeout countdown between matches, replacing any timeout already running
+func (a *Arena) StartTimeout(d time.Duration) error {
+	if d <= 0 {
+		return argumentErrorf("timeout duration %s isn't positive", d)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if running(a.matchState) {
+		return fmt.Errorf("match %s is running", a.match)
+	}
+	log.Infof("Starting %s field timeout", d)
+	a.timeoutEndsAt = a.clock.Now().Add(d)
+	return nil
+}
+
+// CancelTimeout ends the field timeout early
+func (a *Arena) CancelTimeout() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.timeoutRemaining() == 0 {
+		return fmt.Errorf("no field timeout is running")
+	}
+	log.Info("Cancelling field timeout")
+	a.timeoutEndsAt = time.Time{}
+	return nil
+}
+
+// stage moves an idle or finished field into pre-match once the next match is being configured
+func (a *Arena) stage() error {
+	if running(a.matchState) {
+		return fmt.Errorf("match %s is running", a.match)
+	}
+	if a.matchState == statePreMatch || a.matchState == stateFieldTest {
+		return nil
+	}
+	return a.transition(statePreMatch)
+}
+
 // PlayAllSounds plays all game sounds to test audio levels
-func PlayAllSounds() {
-	playSound("auto.mp3")
-	playSound("teleop.mp3")
-	playSound("endgame.mp3")
-	playSound("end.mp3")
-	playSound("abort.mp3")
+func (a *Arena) PlayAllSounds() {
+	a.playSound("auto.mp3")
+	a.playSound("teleop.mp3")
+	a.playSound("endgame.mp3")
+	a.playSound("end.mp3")
+	a.playSound("abort.mp3")
 }
 
 // UpdateTeamNumbers updates all alliance station team numbers
-func UpdateTeamNumbers(alliances map[string]int) {
-	if driverstation.AllianceStations == nil {
-		driverstation.AllianceStations = map[string]*driverstation.AllianceStation{}
+func (a *Arena) UpdateTeamNumbers(teams map[string]int) error {
+	for position, team := range teams {
+		if err := validateStation(position); err != nil {
+			return err
+		}
+		if team < 0 || team > maxTeamNumber {
+			return argumentErrorf("team number %d in %s is outside 0 to %d", team, position, maxTeamNumber)
+		}
 	}
 
-	for position, team := range alliances {
-		if driverstation.AllianceStations[position] == nil {
-			driverstation.AllianceStations[position] = &driverstation.AllianceStation{Team: team}
-		} else {
-			driverstation.AllianceStations[position].Team = team
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// A new team would inherit the station's e-stop
+	current := a.ds.TeamNumbers()
+	var estopped []string
+	for _, position := range a.ds.Estopped() {
+		if team, ok := teams[position]; ok && team != current[position] {
+			estopped = append(estopped, position)
 		}
 	}
+	if len(estopped) > 0 {
+		return fmt.Errorf("clear the e-stops of %s before changing their teams", strings.Join(estopped, ", "))
+	}
+
+	if err := a.stage(); err != nil {
+		return err
+	}
+	a.ds.SetTeams(teams)
+	return nil
 }
 
 // TeamNumbers gets a map of alliance station position to team number
-func TeamNumbers() map[string]int {
-	var o = make(map[string]int, len(driverstation.AllianceStations))
-	for position, allianceStation := range driverstation.AllianceStations {
-		o[position] = allianceStation.Team
-	}
-	return o
+func (a *Arena) TeamNumbers() map[string]int {
+	return a.ds.TeamNumbers()
 }
 
-// UpdateMatchName sets the match name
-func UpdateMatchName(n string) {
-	log.Infof("Updating match name to %s", n)
-	matchName = n
+// UpdateMatch sets the type, number and replay of the next match
+func (a *Arena) UpdateMatch(m Match) error {
+	if err := m.Validate(); err != nil {
+		return &ArgumentError{err}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.stage(); err != nil {
+		return err
+	}
+	log.Infof("Updating match to %s", m)
+	a.match = m
+	return nil
 }
 
 // ResetAlliances clears all alliance stations
-func ResetAlliances() {
+func (a *Arena) ResetAlliances() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if running(a.matchState) {
+		return fmt.Errorf("match %s is running", a.match)
+	}
+	if estopped := a.ds.Estopped(); len(estopped) > 0 {
+		return fmt.Errorf("clear the e-stops of %s first", strings.Join(estopped, ", "))
+	}
 	log.Info("Resetting alliances")
-	driverstation.CloseAll()
-	driverstation.AllianceStations = map[string]*driverstation.AllianceStation{}
+	a.ds.ResetAlliances()
+	if a.matchState != stateIdle {
+		return a.transition(stateIdle)
+	}
+	return nil
+}
+
+// SetSpeed sets how many times faster than real time matches run, to rehearse with demo matches
+func (a *Arena) SetSpeed(speed float64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if running(a.matchState) {
+		return fmt.Errorf("match %s is running", a.match)
+	}
+	if err := a.clock.SetSpeed(speed); err != nil {
+		return &ArgumentError{err}
+	}
+	log.Infof("Setting match speed to %gx", speed)
+	return nil
+}
+
+// SetBypass sets whether an alliance station is bypassed, so a missing team doesn't hold up the match
+func (a *Arena) SetBypass(allianceStation string, bypass bool) error {
+	if err := validateStation(allianceStation); err != nil {
+		return err
+	}
+	log.Infof("Setting %s bypass to %v", allianceStation, bypass)
+	return a.ds.SetBypass(allianceStation, bypass)
+}
+
+// SetAllianceBypass sets whether every station of an alliance ("red" or "blue") is bypassed
+func (a *Arena) SetAllianceBypass(alliance string, bypass bool) error {
+	alliance = strings.ToLower(alliance)
+	if alliance != "red" && alliance != "blue" {
+		return argumentErrorf("unknown alliance %q", alliance)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := a.SetBypass(fmt.Sprintf("%s%d", strings.ToUpper(alliance[:1]), i), bypass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartComms starts driver station communication
+func (a *Arena) StartComms() error {
+	return a.ds.Start()
+}
+
+// ResetComms forces all driver stations to reconnect. It fails if communication was never started.
+func (a *Arena) ResetComms() error {
+	return a.ds.Reset()
+}
+
+// StartFieldTest enters field test mode, where robots are enabled one station at a time for checkout
+func (a *Arena) StartFieldTest() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if running(a.matchState) {
+		return fmt.Errorf("match %s is running", a.match)
+	}
+	if err := a.transition(stateFieldTest); err != nil {
+		return err
+	}
+	a.ds.StopMatch()
+	return nil
+}
+
+// StopFieldTest disables every robot and leaves field test mode
+func (a *Arena) StopFieldTest() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.matchState != stateFieldTest {
+		return fmt.Errorf("field isn't in test mode")
+	}
+	a.ds.StopMatch()
+	return a.transition(stateIdle)
+}
+
+// SetRobotMode puts a robot into teleop, auto or test mode and enables or disables it while the field is in test mode
+func (a *Arena) SetRobotMode(allianceStation string, mode driverstation.RobotMode, enabled bool) error {
+	if err := validateStation(allianceStation); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.matchState != stateFieldTest {
+		return fmt.Errorf("field isn't in test mode")
+	}
+	log.Infof("Field test: setting %s to %s (enabled: %v)", allianceStation, mode, enabled)
+	return a.ds.SetRobotMode(allianceStation, mode, enabled)
+}
+
+// Astop disables an alliance member for the rest of auto
+func (a *Arena) Astop(allianceStation string) error {
+	if err := validateStation(allianceStation); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.matchState != stateStartMatch && a.matchState != stateAuto {
+		return fmt.Errorf("A-stop is only available during auto")
+	}
+	log.Infof("Match %s: A-stopping %s", a.match, allianceStation)
+	return a.ds.Astop(allianceStation)
+}
+
+// startRecord starts the record of a new match, keeping only e-stops that haven't been cleared. Callers must hold a.mu.
+func (a *Arena) startRecord() {
+	a.record = &MatchRecord{
+		Match:     a.match,
+		Name:      a.match.Name(),
+		Teams:     a.ds.TeamNumbers(),
+		StartedAt: a.cfg.Clock.Now(),
+		Estops:    []*EstopRecord{},
+	}
+
+	// E-stops that are still in place carry over into the new record
+	var estops []*EstopRecord
+	for _, record := range a.estops {
+		if record.ClearedAt == nil {
+			estops = append(estops, record)
+			a.record.Estops = append(a.record.Estops, record)
+		}
+	}
+	a.estops = estops
+}
+
+// endRecord finishes and saves the record of the current match. Callers must hold a.mu.
+func (a *Arena) endRecord(aborted bool) {
+	if a.record == nil {
+		return
+	}
+	a.record.EndedAt = a.cfg.Clock.Now()
+	a.record.Aborted = aborted
+	a.saveRecord(a.record)
+}
+
+// Estop e-stops an alliance member until an operator clears it after the match. source records what triggered it.
+func (a *Arena) Estop(allianceStation, source string) error {
+	if err := validateStation(allianceStation); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	team, err := a.ds.Estop(allianceStation)
+	if err != nil {
+		return err
+	}
+	log.Warnf("Match %s: %s e-stopped team %d in %s", a.match, source, team, allianceStation)
+
+	record := &EstopRecord{
+		Station:   allianceStation,
+		Team:      team,
+		Match:     a.match.Name(),
+		Time:      a.cfg.Clock.Now(),
+		MatchTime: math.Round(a.matchTime().Seconds()*1000) / 1000,
+		Source:    source,
+	}
+	a.estops = append(a.estops, record)
+	if running(a.matchState) && a.record != nil {
+		record.record = a.record
+		a.record.Estops = append(a.record.Estops, record)
+	}
+	a.auditEstop("estop", record)
+	return nil
+}
+
+// ClearEstop clears an alliance member's e-stop once the match is over. source records who cleared it.
+func (a *Arena) ClearEstop(allianceStation, source string) error {
+	if err := validateStation(allianceStation); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if running(a.matchState) {
+		return fmt.Errorf("e-stops can't be cleared until match %s ends", a.match)
+	}
+	if err := a.ds.ClearEstop(allianceStation); err != nil {
+		return err
+	}
+	log.Infof("%s cleared the e-stop of %s", source, allianceStation)
+
+	now := a.cfg.Clock.Now()
+	for _, record := range a.estops {
+		if record.Station != allianceStation || record.ClearedAt != nil {
+			continue
+		}
+		record.ClearedAt = &now
+		record.ClearedBy = source
+		a.auditEstop("clear", record)
+
+		// Match records share e-stop entries with the audit trail, so save the match the e-stop happened in and
+		// the last match, which it may have carried over into, again to include the clear
+		a.saveRecord(record.record)
+		if a.record != nil && a.record != record.record && a.record.lists(record) {
+			a.saveRecord(a.record)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,991 @@
+package field
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natesales/bunnyfms/internal/clock"
+	"github.com/natesales/bunnyfms/internal/driverstation"
+	"github.com/natesales/bunnyfms/internal/driverstation/dstest"
+)
+
+var testTeams = map[string]int{"R1": 254, "R2": 1678, "R3": 118, "B1": 971, "B2": 2056, "B3": 4414}
+
+// testArena is an arena running on a fake clock with a fake driver station in every station
+type testArena struct {
+	*Arena
+	clock     *clock.Manual
+	transport *dstest.Transport
+	ds        map[string]*dstest.DriverStation
+}
+
+// testStart is the wall clock time tests start at
+var testStart = time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+
+func newTestArena(t *testing.T) *testArena {
+	return newTestArenaAtSpeed(t, 1)
+}
+
+func newTestArenaAtSpeed(t *testing.T, speed float64) *testArena {
+	t.Helper()
+	base := clock.NewManual(testStart)
+	transport := &dstest.Transport{}
+	a, err := NewArena(Config{
+		AutoDuration:    15 * time.Second,
+		PauseDuration:   3 * time.Second,
+		TeleopDuration:  2*time.Minute + 15*time.Second,
+		EndgameDuration: 30 * time.Second,
+		GameDataDelay:   5 * time.Second,
+		Clock:           base,
+		Speed:           speed,
+		DriverStation:   driverstation.Config{Clock: base, Transport: transport},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.UpdateTeamNumbers(testTeams); err != nil {
+		t.Fatal(err)
+	}
+
+	ta := &testArena{Arena: a, clock: base, transport: transport, ds: map[string]*dstest.DriverStation{}}
+	for station := range testTeams {
+		if ta.ds[station], err = transport.Attach(station); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		if running(ta.State()["state"].(string)) {
+			ta.Stop()
+		}
+	})
+	return ta
+}
+
+// step advances the clock, lets the match react and sends a round of control packets
+func (ta *testArena) step(d time.Duration) {
+	ta.clock.Advance(d)
+	ta.update()
+	ta.transport.SendControlPackets()
+}
+
+// expectPackets checks the control bits most recently sent to every driver station
+func (ta *testArena) expectPackets(t *testing.T, auto, enabled, estop bool) {
+	t.Helper()
+	for station, ds := range ta.ds {
+		packet, ok := ds.LastControlPacket()
+		if !ok {
+			t.Fatalf("%s: no control packet sent", station)
+		}
+		if packet.Auto != auto || packet.Enabled != enabled || packet.Estop != estop {
+			t.Errorf("%s: got auto=%v enabled=%v estop=%v, want auto=%v enabled=%v estop=%v",
+				station, packet.Auto, packet.Enabled, packet.Estop, auto, enabled, estop)
+		}
+		if packet.Station != stationPosition(station) {
+			t.Errorf("%s: got station %d", station, packet.Station)
+		}
+	}
+}
+
+func stationPosition(station string) byte {
+	return map[string]byte{"R1": 0, "R2": 1, "R3": 2, "B1": 3, "B2": 4, "B3": 5}[station]
+}
+
+// expectState checks the match state and timers reported to the UI
+func (ta *testArena) expectState(t *testing.T, state, current, auto, teleop, endgame string) {
+	t.Helper()
+	s := ta.State()
+	want := map[string]string{
+		"state":         state,
+		"current_timer": current,
+		"auto_timer":    auto,
+		"teleop_timer":  teleop,
+		"endgame_timer": endgame,
+	}
+	for key, value := range want {
+		if s[key] != value {
+			t.Errorf("%s: got %v, want %s", key, s[key], value)
+		}
+	}
+	if s["running"] != running(state) {
+		t.Errorf("running: got %v in %s", s["running"], state)
+	}
+}
+
+// expectSecondsRemaining checks the period time sent to every driver station
+func (ta *testArena) expectSecondsRemaining(t *testing.T, seconds int) {
+	t.Helper()
+	for station, ds := range ta.ds {
+		packet, _ := ds.LastControlPacket()
+		if packet.SecondsRemaining != seconds {
+			t.Errorf("%s: got %d seconds remaining, want %d", station, packet.SecondsRemaining, seconds)
+		}
+	}
+}
+
+func TestMatchLifecycle(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.UpdateMatch(Match{Type: driverstation.MatchTypeQualification, Number: 12, Replay: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetGameData("red", strings.Repeat("L", driverstation.MaxGameDataLength+1)); err == nil {
+		t.Fatal("set game data longer than its size byte allows")
+	}
+	if err := ta.SetGameData("red", strings.Repeat("L", driverstation.MaxGameDataLength)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetGameData("red", "L"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetGameData("blue", "R"); err != nil {
+		t.Fatal(err)
+	}
+
+	ta.step(0)
+	ta.expectState(t, statePreMatch, "0:00", "0:15", "2:15", "0:30")
+	ta.expectPackets(t, false, false, false)
+	ta.expectSecondsRemaining(t, 15)
+	for _, ds := range ta.ds {
+		packet, _ := ds.LastControlPacket()
+		if packet.MatchType != byte(driverstation.MatchTypeQualification) || packet.MatchNumber != 12 || packet.Replay != 1 {
+			t.Fatalf("got match %d/%d/%d", packet.MatchType, packet.MatchNumber, packet.Replay)
+		}
+	}
+
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Start(false); err == nil {
+		t.Fatal("started a match that was already running")
+	}
+
+	// Auto
+	ta.step(0)
+	ta.expectState(t, stateAuto, "0:15", "0:15", "2:15", "0:30")
+	ta.expectPackets(t, true, true, false)
+	ta.step(5 * time.Second)
+	ta.expectState(t, stateAuto, "0:10", "0:10", "2:15", "0:30")
+	ta.expectPackets(t, true, true, false)
+	ta.expectSecondsRemaining(t, 10)
+	ta.step(10*time.Second - time.Millisecond)
+	ta.expectState(t, stateAuto, "0:00", "0:00", "2:15", "0:30")
+	ta.expectPackets(t, true, true, false)
+	ta.expectSecondsRemaining(t, 1)
+
+	// Pause
+	ta.step(time.Millisecond)
+	ta.expectState(t, statePause, "0:03", "-", "2:15", "0:30")
+	ta.expectPackets(t, true, false, false)
+	ta.expectSecondsRemaining(t, 135)
+	ta.step(2 * time.Second)
+	ta.expectState(t, statePause, "0:01", "-", "2:15", "0:30")
+	ta.expectPackets(t, true, false, false)
+
+	// Teleop
+	ta.step(time.Second)
+	ta.expectState(t, stateTeleop, "2:15", "-", "2:15", "0:30")
+	ta.expectPackets(t, false, true, false)
+	ta.expectSecondsRemaining(t, 135)
+	for station, ds := range ta.ds {
+		if gameData := ds.GameData(); len(gameData) != 1 || gameData[0] != "" {
+			t.Fatalf("%s: got game data %q before the delay, want only the reset", station, gameData)
+		}
+	}
+	ta.step(5 * time.Second)
+	ta.expectState(t, stateTeleop, "2:10", "-", "2:10", "0:30")
+	ta.expectPackets(t, false, true, false)
+	for station, ds := range ta.ds {
+		want := "L"
+		if station[0] == 'B' {
+			want = "R"
+		}
+		if gameData := ds.GameData(); len(gameData) != 2 || gameData[1] != want {
+			t.Fatalf("%s: got game data %q, want %q", station, gameData, want)
+		}
+	}
+	ta.step(time.Minute)
+	ta.expectState(t, stateTeleop, "1:10", "-", "1:10", "0:30")
+	ta.expectPackets(t, false, true, false)
+
+	// Endgame is part of teleop, so robots stay enabled right up to the end of the match
+	ta.step(40 * time.Second)
+	ta.expectState(t, stateTeleop, "0:30", "-", "0:30", "0:30")
+	ta.expectPackets(t, false, true, false)
+	ta.expectSecondsRemaining(t, 30)
+	if ta.State()["endgame"] != true {
+		t.Fatal("endgame isn't reported")
+	}
+	ta.step(20 * time.Second)
+	ta.expectState(t, stateTeleop, "0:10", "-", "0:10", "0:10")
+	ta.expectPackets(t, false, true, false)
+	ta.expectSecondsRemaining(t, 10)
+	ta.step(10*time.Second - time.Millisecond)
+	ta.expectState(t, stateTeleop, "0:00", "-", "0:00", "0:00")
+	ta.expectPackets(t, false, true, false)
+	ta.expectSecondsRemaining(t, 1)
+
+	// Post-match
+	ta.step(time.Millisecond)
+	ta.expectState(t, statePostMatch, "0:00", "0:15", "2:15", "0:30")
+	ta.expectPackets(t, false, false, false)
+	ta.expectSecondsRemaining(t, 0)
+	if ta.State()["endgame"] != false {
+		t.Fatal("endgame is still reported after the match")
+	}
+	ta.step(time.Minute)
+	ta.expectState(t, statePostMatch, "0:00", "0:15", "2:15", "0:30")
+	ta.expectPackets(t, false, false, false)
+
+	if err := ta.Stop(); err == nil {
+		t.Fatal("stopped a match that had already ended")
+	}
+}
+
+func TestMatchEstop(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.step(15 * time.Second)
+	ta.step(3 * time.Second)
+	ta.expectPackets(t, false, true, false)
+
+	if err := ta.Estop("B2", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	for station, ds := range ta.ds {
+		packet, _ := ds.LastControlPacket()
+		if packet.Estop != (station == "B2") {
+			t.Errorf("%s: got estop=%v", station, packet.Estop)
+		}
+		if packet.Enabled != (station != "B2") {
+			t.Errorf("%s: got enabled=%v", station, packet.Enabled)
+		}
+	}
+}
+
+func TestMatchAbort(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.step(5 * time.Second)
+	ta.expectPackets(t, true, true, false)
+
+	if err := ta.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.expectState(t, stateIdle, "0:00", "0:15", "2:15", "0:30")
+	ta.expectPackets(t, true, false, false)
+	ta.expectSecondsRemaining(t, 15)
+
+	// Time passing after an abort must not move the match on
+	ta.step(time.Minute)
+	ta.expectState(t, stateIdle, "0:00", "0:15", "2:15", "0:30")
+	ta.expectPackets(t, true, false, false)
+}
+
+func TestMatchDemoSpeed(t *testing.T) {
+	ta := newTestArenaAtSpeed(t, 10)
+	if ta.State()["speed"] != 10.0 {
+		t.Fatalf("got speed %v", ta.State()["speed"])
+	}
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetSpeed(1); err == nil {
+		t.Fatal("changed speed during a match")
+	}
+
+	ta.step(0)
+	ta.step(time.Second)
+	ta.expectState(t, stateAuto, "0:05", "0:05", "2:15", "0:30")
+	ta.step(500 * time.Millisecond)
+	ta.expectState(t, statePause, "0:03", "-", "2:15", "0:30")
+	ta.expectPackets(t, true, false, false)
+
+	// Driver stations still get the real time
+	for station, ds := range ta.ds {
+		packet, _ := ds.LastControlPacket()
+		if want := testStart.Add(1500 * time.Millisecond); !packet.Time.Equal(want) {
+			t.Errorf("%s: got time %s, want %s", station, packet.Time, want)
+		}
+	}
+
+	if err := ta.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetSpeed(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetSpeed(0); err == nil {
+		t.Fatal("accepted speed 0")
+	}
+}
+
+func TestMatchPause(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.Pause(); err == nil {
+		t.Fatal("paused without a match running")
+	}
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.step(5 * time.Second)
+	ta.expectState(t, stateAuto, "0:10", "0:10", "2:15", "0:30")
+
+	if err := ta.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Pause(); err == nil {
+		t.Fatal("paused a match twice")
+	}
+	ta.step(0)
+	ta.expectPackets(t, true, false, false)
+
+	// Nothing moves while paused, however long the fault takes to fix
+	ta.step(5 * time.Minute)
+	ta.expectState(t, stateAuto, "0:10", "0:10", "2:15", "0:30")
+	ta.expectPackets(t, true, false, false)
+	ta.expectSecondsRemaining(t, 10)
+	if ta.State()["paused"] != true {
+		t.Fatal("match isn't reported as paused")
+	}
+
+	if err := ta.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Resume(); err == nil {
+		t.Fatal("resumed a match that wasn't paused")
+	}
+	ta.step(0)
+	ta.expectState(t, stateAuto, "0:10", "0:10", "2:15", "0:30")
+	ta.expectPackets(t, true, true, false)
+	ta.step(10 * time.Second)
+	ta.expectState(t, statePause, "0:03", "-", "2:15", "0:30")
+
+	// Pausing during teleop re-enables robots in teleop on resume
+	ta.step(3 * time.Second)
+	ta.step(15 * time.Second)
+	ta.expectState(t, stateTeleop, "2:00", "-", "2:00", "0:30")
+	if err := ta.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Minute)
+	ta.expectPackets(t, false, false, false)
+	ta.expectState(t, stateTeleop, "2:00", "-", "2:00", "0:30")
+	if err := ta.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectPackets(t, false, true, false)
+	ta.expectState(t, stateTeleop, "1:59", "-", "1:59", "0:30")
+
+	// Aborting a paused match leaves nothing paused
+	if err := ta.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if ta.State()["paused"] != false {
+		t.Fatal("aborted match is still paused")
+	}
+}
+
+func TestFieldTimeout(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.StartTimeout(0); err == nil {
+		t.Fatal("started an empty timeout")
+	}
+	if err := ta.StartTimeout(6 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	ta.step(time.Minute)
+	ta.expectState(t, statePreMatch, "5:00", "0:15", "2:15", "0:30")
+	if ta.State()["timeout"] != true {
+		t.Fatal("timeout isn't reported")
+	}
+	ta.step(5 * time.Minute)
+	ta.expectState(t, statePreMatch, "0:00", "0:15", "2:15", "0:30")
+	if ta.State()["timeout"] != false {
+		t.Fatal("timeout is still reported after it ended")
+	}
+	if err := ta.CancelTimeout(); err == nil {
+		t.Fatal("cancelled a timeout that had ended")
+	}
+
+	if err := ta.StartTimeout(3 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.StartTimeout(3 * time.Minute); err == nil {
+		t.Fatal("started a timeout during a match")
+	}
+	if err := ta.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if ta.State()["timeout"] != false {
+		t.Fatal("starting a match didn't end the timeout")
+	}
+
+	if err := ta.StartTimeout(3 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.CancelTimeout(); err != nil {
+		t.Fatal(err)
+	}
+	ta.expectState(t, stateIdle, "0:00", "0:15", "2:15", "0:30")
+}
+
+func TestEndgameWarningOnly(t *testing.T) {
+	for _, endgame := range []time.Duration{0, 30 * time.Second, 2*time.Minute + 15*time.Second} {
+		t.Run(endgame.String(), func(t *testing.T) {
+			ta := newTestArena(t)
+			ta.cfg.EndgameDuration = endgame
+			if err := ta.Start(false); err != nil {
+				t.Fatal(err)
+			}
+			ta.step(0)
+			ta.step(15 * time.Second)
+			ta.step(3 * time.Second)
+			ta.step(0) // First update in teleop
+
+			// Walk through teleop a second at a time; robots must never be disabled early
+			for elapsed := time.Duration(0); elapsed < 2*time.Minute+15*time.Second; elapsed += time.Second {
+				if state := ta.State()["state"]; state != stateTeleop {
+					t.Fatalf("%s into teleop: got state %v", elapsed, state)
+				}
+				ta.expectPackets(t, false, true, false)
+				wantEndgame := endgame > 0 && elapsed >= 2*time.Minute+15*time.Second-endgame
+				if ta.State()["endgame"] != wantEndgame {
+					t.Fatalf("%s into teleop: got endgame %v, want %v", elapsed, ta.State()["endgame"], wantEndgame)
+				}
+				ta.step(time.Second)
+			}
+			ta.expectState(t, statePostMatch, "0:00", "0:15", "2:15", formatDuration(endgame))
+			ta.expectPackets(t, false, false, false)
+		})
+	}
+}
+
+// expectEnabled checks which robots were enabled in the most recent control packets
+func (ta *testArena) expectEnabled(t *testing.T, enabled map[string]bool) {
+	t.Helper()
+	for station, ds := range ta.ds {
+		packet, _ := ds.LastControlPacket()
+		if packet.Enabled != enabled[station] {
+			t.Errorf("%s: got enabled=%v, want %v", station, packet.Enabled, enabled[station])
+		}
+	}
+}
+
+func TestBypass(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.SetBypass("R4", true); err == nil {
+		t.Fatal("bypassed an unknown station")
+	}
+	if err := ta.SetAllianceBypass("green", true); err == nil {
+		t.Fatal("bypassed an unknown alliance")
+	}
+	if err := ta.SetBypass("R2", true); err != nil {
+		t.Fatal(err)
+	}
+	if bypass := ta.State()["bypass"].(map[string]bool); !bypass["R2"] || bypass["R1"] || len(bypass) != 6 {
+		t.Fatalf("got bypass %v", bypass)
+	}
+
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.expectEnabled(t, map[string]bool{"R1": true, "R3": true, "B1": true, "B2": true, "B3": true})
+
+	// Un-bypassing mid-match doesn't enable the robot until the next period
+	if err := ta.SetBypass("R2", false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectEnabled(t, map[string]bool{"R1": true, "R3": true, "B1": true, "B2": true, "B3": true})
+	ta.step(14 * time.Second)
+	ta.step(3 * time.Second)
+	ta.expectEnabled(t, map[string]bool{"R1": true, "R2": true, "R3": true, "B1": true, "B2": true, "B3": true})
+
+	// Bypassing mid-match disables robots straight away
+	if err := ta.SetAllianceBypass("Blue", true); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectEnabled(t, map[string]bool{"R1": true, "R2": true, "R3": true})
+	if bypass := ta.State()["bypass"].(map[string]bool); !bypass["B1"] || !bypass["B2"] || !bypass["B3"] || bypass["R2"] {
+		t.Fatalf("got bypass %v", bypass)
+	}
+
+	// Resuming a paused match keeps bypassed robots disabled
+	if err := ta.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectEnabled(t, map[string]bool{"R1": true, "R2": true, "R3": true})
+}
+
+func TestReadiness(t *testing.T) {
+	ta := newTestArena(t)
+	for station, links := range map[string][3]bool{"R1": {true, false, false}, "B1": {true, true, false}, "B2": {false, false, false}} {
+		if err := ta.ds[station].SetLinks(links[0], links[1], links[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ta.Estop("R3", "admin")
+
+	want := map[string][]string{
+		"R1": {"radio not linked", "robot not linked"},
+		"R2": {},
+		"R3": {"e-stopped"},
+		"B1": {"robot not linked"},
+		"B2": {"DS not linked"},
+		"B3": {},
+	}
+	s := ta.State()
+	if s["ready"] != false {
+		t.Fatal("field is ready with unlinked robots")
+	}
+	readiness := s["readiness"].(map[string][]string)
+	for station, reasons := range want {
+		if strings.Join(readiness[station], ",") != strings.Join(reasons, ",") {
+			t.Errorf("%s: got reasons %q, want %q", station, readiness[station], reasons)
+		}
+		if readiness[station] == nil {
+			t.Errorf("%s: reasons are nil rather than empty", station)
+		}
+	}
+
+	err := ta.Start(false)
+	if err == nil {
+		t.Fatal("started a match that wasn't ready")
+	}
+	if !strings.Contains(err.Error(), "B2: DS not linked") {
+		t.Errorf("got error %q, which doesn't name B2", err)
+	}
+
+	// Bypassed stations don't count, however unready they are
+	for _, station := range []string{"R1", "R3", "B1", "B2"} {
+		if err := ta.SetBypass(station, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ta.State()["ready"] != true {
+		t.Fatalf("field isn't ready: %v", ta.State()["readiness"])
+	}
+	if err := ta.SetBypass("B1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Operators can start anyway
+	if err := ta.Start(true); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.expectEnabled(t, map[string]bool{"R2": true, "B1": true, "B3": true})
+}
+
+func TestReadinessEmptyStation(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.ResetAlliances(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.UpdateTeamNumbers(map[string]int{"R1": 254, "B1": 971}); err != nil {
+		t.Fatal(err)
+	}
+	for _, station := range []string{"R1", "B1"} {
+		if _, err := ta.transport.Attach(station); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reasons := ta.State()["readiness"].(map[string][]string)["R2"]; len(reasons) != 1 || reasons[0] != "no team" {
+		t.Fatalf("got reasons %q for an empty station", reasons)
+	}
+	if err := ta.Start(false); err == nil {
+		t.Fatal("started with empty stations that weren't bypassed")
+	}
+	for _, station := range []string{"R2", "R3", "B2", "B3"} {
+		if err := ta.SetBypass(station, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadinessTeamChange(t *testing.T) {
+	ta := newTestArena(t)
+	oldDS := ta.ds["R1"]
+	delete(ta.ds, "R1")
+
+	// R1's new team hasn't connected, so the previous team's DS doesn't make the station ready
+	if err := ta.UpdateTeamNumbers(map[string]int{"R1": 604}); err != nil {
+		t.Fatal(err)
+	}
+	if reasons := ta.State()["readiness"].(map[string][]string)["R1"]; strings.Join(reasons, ",") != "DS not linked" {
+		t.Fatalf("got reasons %q for R1 after a team change", reasons)
+	}
+	if err := ta.Start(false); err == nil {
+		t.Fatal("started with the previous team's DS in R1")
+	}
+
+	// Forcing the match doesn't enable the previous team's robot
+	if err := ta.Start(true); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.step(5 * time.Second)
+	ta.expectEnabled(t, map[string]bool{"R2": true, "R3": true, "B1": true, "B2": true, "B3": true})
+	for _, packet := range oldDS.ControlPackets() {
+		if packet.Enabled {
+			t.Fatalf("previous R1 team was enabled: %s", packet)
+		}
+	}
+}
+
+func TestAstop(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.Astop("R1"); err == nil {
+		t.Fatal("A-stopped before the match")
+	}
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.step(5 * time.Second)
+	if err := ta.Astop("R1"); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectEnabled(t, map[string]bool{"R2": true, "R3": true, "B1": true, "B2": true, "B3": true})
+	packet, _ := ta.ds["R1"].LastControlPacket()
+	if !packet.Astop || packet.Estop {
+		t.Fatalf("got astop=%v estop=%v", packet.Astop, packet.Estop)
+	}
+	if !ta.State()["ds"].(map[string]*driverstation.DSStats)["R1"].Astop {
+		t.Fatal("A-stop isn't reported")
+	}
+
+	// A-stop survives a pause in auto
+	if err := ta.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectEnabled(t, map[string]bool{"R2": true, "R3": true, "B1": true, "B2": true, "B3": true})
+
+	// Teleop re-enables the robot
+	ta.step(8 * time.Second)
+	ta.step(3 * time.Second)
+	ta.expectState(t, stateTeleop, "2:15", "-", "2:15", "0:30")
+	ta.expectPackets(t, false, true, false)
+	if packet, _ := ta.ds["R1"].LastControlPacket(); packet.Astop {
+		t.Fatal("A-stop is still set in teleop")
+	}
+	if err := ta.Astop("R1"); err == nil {
+		t.Fatal("A-stopped during teleop")
+	}
+}
+
+func TestFieldTest(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.SetRobotMode("R1", driverstation.RobotModeTeleop, true); err == nil {
+		t.Fatal("enabled a robot outside field test mode")
+	}
+	if err := ta.StartFieldTest(); err != nil {
+		t.Fatal(err)
+	}
+	ta.expectState(t, stateFieldTest, "0:00", "0:15", "2:15", "0:30")
+	if err := ta.Start(false); err == nil {
+		t.Fatal("started a match during field test")
+	}
+
+	if err := ta.SetRobotMode("R1", driverstation.RobotModeTest, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetRobotMode("B2", driverstation.RobotModeAuto, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetRobotMode("B3", driverstation.RobotModeTeleop, false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectEnabled(t, map[string]bool{"R1": true, "B2": true})
+	for station, ds := range ta.ds {
+		packet, _ := ds.LastControlPacket()
+		if packet.Test != (station == "R1") || packet.Auto != (station == "B2") {
+			t.Errorf("%s: got test=%v auto=%v", station, packet.Test, packet.Auto)
+		}
+	}
+	if stats := ta.State()["ds"].(map[string]*driverstation.DSStats); stats["R1"].Mode != "test" || !stats["R1"].Enabled || stats["B2"].Mode != "auto" {
+		t.Fatalf("got R1 %s enabled=%v, B2 %s", stats["R1"].Mode, stats["R1"].Enabled, stats["B2"].Mode)
+	}
+
+	// Changing one station leaves the rest alone
+	if err := ta.SetRobotMode("R1", driverstation.RobotModeTest, false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectEnabled(t, map[string]bool{"B2": true})
+
+	if err := ta.SetBypass("R3", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.SetRobotMode("R3", driverstation.RobotModeTeleop, true); err == nil {
+		t.Fatal("enabled a bypassed robot")
+	}
+
+	if err := ta.StopFieldTest(); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	ta.expectState(t, stateIdle, "0:00", "0:15", "2:15", "0:30")
+	ta.expectEnabled(t, map[string]bool{})
+	for station, ds := range ta.ds {
+		if packet, _ := ds.LastControlPacket(); packet.Test {
+			t.Errorf("%s: still in test mode", station)
+		}
+	}
+}
+
+func TestEstopPersistsUntilCleared(t *testing.T) {
+	ta := newTestArena(t)
+	dir := t.TempDir()
+	ta.cfg.DriverStation.LogDir = dir
+	if err := ta.Estop("R4", "admin"); err == nil {
+		t.Fatal("e-stopped an unknown station")
+	}
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	ta.step(5 * time.Second)
+	if err := ta.Estop("R2", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.ClearEstop("R2", "admin"); err == nil {
+		t.Fatal("cleared an e-stop mid-match")
+	}
+
+	// The e-stop lasts through the rest of the match, even if the DS reconnects
+	ta.step(10 * time.Second)
+	ta.step(3 * time.Second)
+	var err error
+	if ta.ds["R2"], err = ta.transport.Attach("R2"); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(time.Second)
+	if packet, _ := ta.ds["R2"].LastControlPacket(); !packet.Estop || packet.Enabled {
+		t.Fatalf("got estop=%v enabled=%v in teleop", packet.Estop, packet.Enabled)
+	}
+	ta.step(2*time.Minute + 14*time.Second)
+	ta.expectState(t, statePostMatch, "0:00", "0:15", "2:15", "0:30")
+	if packet, _ := ta.ds["R2"].LastControlPacket(); !packet.Estop {
+		t.Fatal("e-stop was cleared by the end of the match")
+	}
+
+	estops := ta.State()["estops"].([]EstopRecord)
+	if len(estops) != 1 {
+		t.Fatalf("got %d e-stop records", len(estops))
+	}
+	want := EstopRecord{Station: "R2", Team: 1678, Match: "Practice", Time: testStart.Add(5 * time.Second), MatchTime: 5, Source: "admin", record: ta.record}
+	if estops[0] != want {
+		t.Fatalf("got e-stop record %+v, want %+v", estops[0], want)
+	}
+
+	// The e-stop holds up the next match until it is cleared
+	if err := ta.ResetAlliances(); err == nil {
+		t.Fatal("reset alliances with an e-stop outstanding")
+	}
+	if err := ta.Start(false); err == nil {
+		t.Fatal("started the next match with an e-stop outstanding")
+	}
+	if err := ta.ClearEstop("R2", "head ref"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.ClearEstop("R2", "head ref"); err == nil {
+		t.Fatal("cleared an e-stop twice")
+	}
+	ta.step(time.Second)
+	if packet, _ := ta.ds["R2"].LastControlPacket(); packet.Estop {
+		t.Fatal("e-stop wasn't cleared")
+	}
+	estops = ta.State()["estops"].([]EstopRecord)
+	if estops[0].ClearedAt == nil || !estops[0].ClearedAt.Equal(testStart.Add(2*time.Minute+33*time.Second)) || estops[0].ClearedBy != "head ref" {
+		t.Fatalf("got cleared at %v by %q", estops[0].ClearedAt, estops[0].ClearedBy)
+	}
+
+	records, err := filepath.Glob(filepath.Join(dir, "*_record.json"))
+	if err != nil || len(records) != 1 {
+		t.Fatalf("got match records %v (%v)", records, err)
+	}
+	data, err := os.ReadFile(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record MatchRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Aborted || len(record.Estops) != 1 || record.Estops[0].ClearedBy != "head ref" || record.Teams["R2"] != 1678 {
+		t.Fatalf("got match record %s", data)
+	}
+
+	// Cleared e-stops drop out of the audit trail when the next match starts
+	if err := ta.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	if estops := ta.State()["estops"].([]EstopRecord); len(estops) != 0 {
+		t.Fatalf("got %d e-stop records in a new match", len(estops))
+	}
+}
+
+func TestEstopBlocksTeamChange(t *testing.T) {
+	ta := newTestArena(t)
+	if err := ta.Estop("R2", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next match's team can't inherit the e-stop, but other stations and the same team are fine
+	err := ta.UpdateTeamNumbers(map[string]int{"R1": 604, "R2": 1323})
+	if err == nil {
+		t.Fatal("changed the team of an e-stopped station")
+	}
+	if !strings.Contains(err.Error(), "R2") {
+		t.Errorf("got error %q, which doesn't name R2", err)
+	}
+	if teams := ta.TeamNumbers(); teams["R1"] != 254 || teams["R2"] != 1678 {
+		t.Fatalf("teams changed by a refused update: %v", teams)
+	}
+	if err := ta.UpdateTeamNumbers(map[string]int{"R1": 604, "R2": 1678}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ta.ClearEstop("R2", "head ref"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.UpdateTeamNumbers(map[string]int{"R2": 1323}); err != nil {
+		t.Fatal(err)
+	}
+	if ta.ds["R2"], err = ta.transport.Attach("R2"); err != nil {
+		t.Fatal(err)
+	}
+	ta.step(0)
+	if packet, _ := ta.ds["R2"].LastControlPacket(); packet.Estop {
+		t.Fatal("new team inherited the cleared e-stop")
+	}
+}
+
+// readRecords reads the saved match records by match number
+func readRecords(t *testing.T, dir string) map[int]MatchRecord {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*_record.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := map[int]MatchRecord{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var record MatchRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatal(err)
+		}
+		records[record.Match.Number] = record
+	}
+	return records
+}
+
+func TestEstopAuditAcrossMatches(t *testing.T) {
+	ta := newTestArena(t)
+	dir := t.TempDir()
+	ta.cfg.DriverStation.LogDir = dir
+
+	runMatch := func(number int, during func()) {
+		t.Helper()
+		if err := ta.UpdateMatch(Match{Type: driverstation.MatchTypeQualification, Number: number, Replay: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ta.Start(true); err != nil {
+			t.Fatal(err)
+		}
+		ta.step(0)
+		if during != nil {
+			during()
+		}
+		ta.step(15 * time.Second)
+		ta.step(3 * time.Second)
+		ta.step(2*time.Minute + 15*time.Second)
+		ta.expectState(t, statePostMatch, "0:00", "0:15", "2:15", "0:30")
+	}
+
+	// R2 is e-stopped in match 1 and B1 after it, and both carry over into match 2, which is forced to start
+	runMatch(1, func() {
+		if err := ta.Estop("R2", "admin"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := ta.Estop("B1", "rest"); err != nil {
+		t.Fatal(err)
+	}
+	runMatch(2, nil)
+	for _, station := range []string{"R2", "B1"} {
+		if err := ta.ClearEstop(station, "head ref"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readRecords(t, dir)
+	if estops := records[1].Estops; len(estops) != 1 || estops[0].Station != "R2" || estops[0].ClearedBy != "head ref" {
+		t.Errorf("got match 1 e-stops %+v, want R2 cleared by the head ref", estops)
+	}
+	estops := records[2].Estops
+	if len(estops) != 2 {
+		t.Fatalf("got match 2 e-stops %+v, want the carried over R2 and B1", estops)
+	}
+	for _, estop := range estops {
+		if estop.ClearedAt == nil || estop.ClearedBy != "head ref" {
+			t.Errorf("match 2 e-stop of %s wasn't cleared: %+v", estop.Station, estop)
+		}
+	}
+
+	// Every e-stop and clear is in the event audit log, including those outside a match
+	data, err := os.ReadFile(filepath.Join(dir, estopsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, entry.Event+" "+entry.Station+" "+entry.Source)
+	}
+	if want := []string{"estop R2 admin", "estop B1 rest", "clear R2 admin", "clear B1 rest"}; strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("got audit log %q, want %q", events, want)
+	}
+}
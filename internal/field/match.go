@@ -0,0 +1,50 @@
+package field
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/natesales/bunnyfms/internal/driverstation"
+)
+
+// Match identifies a match on the event schedule
+type Match struct {
+	Type   driverstation.MatchType `json:"type"`
+	Number int                     `json:"number"`
+	Replay int                     `json:"replay"`
+}
+
+// Validate checks that the match identity fits in a DS control packet
+func (m Match) Validate() error {
+	if _, err := m.Type.MarshalText(); err != nil {
+		return err
+	}
+	if m.Number < 0 || m.Number > 0xffff {
+		return fmt.Errorf("match number %d out of range", m.Number)
+	}
+	if m.Replay < 1 || m.Replay > 0xff {
+		return fmt.Errorf("replay number %d out of range", m.Replay)
+	}
+	return nil
+}
+
+// Name gets a human-readable match name such as "Qualification 12" or "Playoff 3 (replay 2)"
+func (m Match) Name() string {
+	name := strings.ToUpper(m.Type.String()[:1]) + m.Type.String()[1:]
+	if m.Number > 0 {
+		name += fmt.Sprintf(" %d", m.Number)
+	}
+	if m.Replay > 1 {
+		name += fmt.Sprintf(" (replay %d)", m.Replay)
+	}
+	return name
+}
+
+func (m Match) String() string {
+	return m.Name()
+}
+
+// logName gets a filename-safe name for the match such as "qualification-12-1"
+func (m Match) logName() string {
+	return fmt.Sprintf("%s-%d-%d", m.Type, m.Number, m.Replay)
+}
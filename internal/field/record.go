@@ -0,0 +1,113 @@
+package field
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EstopRecord is an audit trail entry for an e-stop
+type EstopRecord struct {
+	Station   string     `json:"station"`
+	Team      int        `json:"team"`
+	Match     string     `json:"match"`
+	Time      time.Time  `json:"time"`
+	MatchTime float64    `json:"match_time"` // Seconds into the match, or 0 if no match was running
+	Source    string     `json:"source"`     // What triggered the e-stop, such as "admin"
+	ClearedAt *time.Time `json:"cleared_at,omitempty"`
+	ClearedBy string     `json:"cleared_by,omitempty"`
+
+	record *MatchRecord // Record of the match the e-stop happened in, or nil if no match was running
+}
+
+// MatchRecord is the summary of a match saved alongside the team match logs
+type MatchRecord struct {
+	Match     Match          `json:"match"`
+	Name      string         `json:"name"`
+	Teams     map[string]int `json:"teams"`
+	StartedAt time.Time      `json:"started_at"`
+	EndedAt   time.Time      `json:"ended_at"`
+	Aborted   bool           `json:"aborted"`
+	Estops    []*EstopRecord `json:"estops"`
+}
+
+// estopsFile is the event-wide e-stop audit log in the log directory, which also covers e-stops outside matches
+const estopsFile = "estops.jsonl"
+
+// auditEntry is a line of the e-stop audit log
+type auditEntry struct {
+	Event string `json:"event"` // "estop" or "clear"
+	*EstopRecord
+}
+
+// saveRecord writes a match record to the log directory. Callers must hold a.mu.
+func (a *Arena) saveRecord(record *MatchRecord) {
+	dir := a.cfg.DriverStation.LogDir
+	if record == nil || dir == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		log.Warnf("Unable to encode match record: %v", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Warnf("Unable to create log directory: %v", err)
+		return
+	}
+	name := fmt.Sprintf("%s_%s_record.json", record.StartedAt.Format("20060102150405"), record.Match.logName())
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		log.Warnf("Unable to write match record: %v", err)
+	}
+}
+
+// auditEstop appends an e-stop or its clearing to the event's e-stop audit log. Callers must hold a.mu.
+func (a *Arena) auditEstop(event string, record *EstopRecord) {
+	dir := a.cfg.DriverStation.LogDir
+	if dir == "" {
+		return
+	}
+
+	data, err := json.Marshal(auditEntry{Event: event, EstopRecord: record})
+	if err != nil {
+		log.Warnf("Unable to encode e-stop audit entry: %v", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Warnf("Unable to create log directory: %v", err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, estopsFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Warnf("Unable to open e-stop audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Warnf("Unable to write e-stop audit log: %v", err)
+	}
+}
+
+// lists reports whether a match record includes an e-stop
+func (r *MatchRecord) lists(estop *EstopRecord) bool {
+	for _, e := range r.Estops {
+		if e == estop {
+			return true
+		}
+	}
+	return false
+}
+
+// estopState copies the e-stop audit trail for the UI
+func (a *Arena) estopState() []EstopRecord {
+	o := make([]EstopRecord, len(a.estops))
+	for i, record := range a.estops {
+		o[i] = *record
+	}
+	return o
+}
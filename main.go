@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -13,9 +14,17 @@ import (
 var (
 	adminListenAddr  = flag.String("admin", "localhost:8080", "Admin listen address")
 	viewerListenAddr = flag.String("viewer", ":8081", "Viewer listen address")
-	autoDuration     = flag.String("auto-duration", "10s", "Auto duration")
-	teleOpDuration   = flag.String("teleop-duration", "2m20s", "Teleop duration")
-	endgameDuration  = flag.String("endgame-duration", "30s", "Endgame duration")
+	autoDuration     = flag.Duration("auto-duration", 10*time.Second, "Auto duration")
+	pauseDuration    = flag.Duration("pause-duration", 3*time.Second, "Pause between auto and teleop")
+	teleOpDuration   = flag.Duration("teleop-duration", 2*time.Minute+20*time.Second, "Teleop duration")
+	endgameDuration  = flag.Duration("endgame-duration", 30*time.Second, "Endgame duration")
+	gameDataDelay    = flag.Duration("game-data-delay", 0, "Time into teleop to send game-specific data")
+	demoSpeed        = flag.Float64("demo-speed", 1, "Run matches this many times faster than real time, for rehearsals")
+	fmsIP            = flag.String("fms-ip", "", "Address to listen for driver stations on (default: detect from the 10.0.100.0/24 field network)")
+	logDir           = flag.String("log-dir", "logs", "Directory to write per-team match logs to (empty to disable)")
+	dsPacketInterval = flag.Duration("ds-packet-interval", driverstation.DefaultControlPacketInterval, "Time between control packets sent to each driver station")
+	dsUdpTimeout     = flag.Duration("ds-udp-timeout", driverstation.DefaultUdpLinkTimeout, "Time without a UDP status packet before a driver station is considered unlinked")
+	dsTcpTimeout     = flag.Duration("ds-tcp-timeout", driverstation.DefaultTcpLinkTimeout, "Time without a TCP packet before a driver station connection is closed")
 	noDriveStations  = flag.Bool("no-ds", false, "Disable drive station communication")
 	noSounds         = flag.Bool("no-sounds", false, "Disable game sounds")
 )
@@ -24,15 +33,33 @@ func main() {
 	flag.Parse()
 	log.SetLevel(log.DebugLevel)
 
-	if err := field.Setup(*autoDuration, *teleOpDuration, *endgameDuration, !*noSounds); err != nil {
+	arena, err := field.NewArena(field.Config{
+		AutoDuration:    *autoDuration,
+		PauseDuration:   *pauseDuration,
+		TeleopDuration:  *teleOpDuration,
+		EndgameDuration: *endgameDuration,
+		GameDataDelay:   *gameDataDelay,
+		Sounds:          !*noSounds,
+		Speed:           *demoSpeed,
+		DriverStation: driverstation.Config{
+			FmsIP:                 *fmsIP,
+			LogDir:                *logDir,
+			ControlPacketInterval: *dsPacketInterval,
+			UdpLinkTimeout:        *dsUdpTimeout,
+			TcpLinkTimeout:        *dsTcpTimeout,
+		},
+	})
+	if err != nil {
 		log.Fatal(err)
 	}
 
 	if !*noDriveStations {
-		driverstation.StartComms()
+		if err := arena.StartComms(); err != nil {
+			log.Fatalf("Unable to start driver station communication: %v", err)
+		}
 	} else {
 		log.Warn("-no-ds flag set, not enabling driver station communication")
 	}
 
-	api.Serve(*adminListenAddr, *viewerListenAddr)
+	api.Serve(arena, *adminListenAddr, *viewerListenAddr)
 }